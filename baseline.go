@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBaselineFile = "pmr-baseline.json"
+
+// runBaseline merges saved findings into a baseline file. Findings in the
+// baseline are accepted risks that `scan -baseline` no longer reports.
+func (cli *CLI) runBaseline(args []string) int {
+	var out string
+
+	flags := flag.NewFlagSet(Name+" baseline", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.StringVar(&out, "out", defaultBaselineFile, "baseline file to create or update")
+	flags.Usage = func() {
+		fmt.Fprintf(cli.errStream, "Usage: %s baseline [-out file] results.json...\n", Name)
+		flags.PrintDefaults()
+	}
+	if err := cli.parse(flags, args[1:]); err != nil {
+		return ExitCodeError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitCodeError
+	}
+
+	findings := []*finding{}
+	if _, err := os.Stat(out); err == nil {
+		if findings, err = readFindings(out); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+	known := map[string]bool{}
+	for _, f := range findings {
+		known[f.key()] = true
+	}
+
+	added := 0
+	for _, p := range flags.Args() {
+		fs, err := readFindings(p)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		for _, f := range fs {
+			if known[f.key()] {
+				continue
+			}
+			known[f.key()] = true
+			findings = append(findings, f)
+			added++
+		}
+	}
+
+	if err := writeFindingsFile(out, findings); err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	fmt.Fprintf(cli.outStream, "%d findings added to %s (%d total)\n", added, out, len(findings))
+	return ExitCodeOK
+}
+
+func readBaseline(path string) (map[string]bool, error) {
+	findings, err := readFindings(path)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, f := range findings {
+		known[f.key()] = true
+	}
+	return known, nil
+}
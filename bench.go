@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bench measures the throughput and latency of a scan, to tune the
+// concurrency and timeouts against a target.
+type bench struct {
+	mu        sync.Mutex
+	started   time.Time
+	latencies []time.Duration
+}
+
+func newBench(now time.Time) *bench {
+	return &bench{started: now}
+}
+
+func (b *bench) add(d time.Duration) {
+	b.mu.Lock()
+	b.latencies = append(b.latencies, d)
+	b.mu.Unlock()
+}
+
+// percentile returns the latency p percent of requests took at most.
+// ls has to be sorted.
+func percentile(ls []time.Duration, p int) time.Duration {
+	if len(ls) == 0 {
+		return 0
+	}
+	i := (len(ls)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return ls[i]
+}
+
+// write prints the throughput, latency percentiles and error rates of
+// the requests recorded until now.
+func (b *bench) write(w io.Writer, st *stats, now time.Time) {
+	b.mu.Lock()
+	ls := append([]time.Duration{}, b.latencies...)
+	b.mu.Unlock()
+	sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+
+	elapsed := now.Sub(b.started)
+	requests := atomic.LoadInt64(&st.requests)
+	rate := func(n int64) float64 {
+		if requests == 0 {
+			return 0
+		}
+		return float64(n) / float64(requests) * 100
+	}
+	fmt.Fprintf(w, "requests:    %d in %s\n", requests, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput:  %.1f requests/s\n", float64(requests)/elapsed.Seconds())
+	fmt.Fprintf(w, "latency:     p50 %s, p95 %s, p99 %s\n",
+		percentile(ls, 50).Round(time.Millisecond), percentile(ls, 95).Round(time.Millisecond), percentile(ls, 99).Round(time.Millisecond))
+	fmt.Fprintf(w, "errors:      %.1f%% (network %.1f%%, dns %.1f%%)\n",
+		rate(atomic.LoadInt64(&st.networkErrors)+atomic.LoadInt64(&st.dnsErrors)),
+		rate(atomic.LoadInt64(&st.networkErrors)), rate(atomic.LoadInt64(&st.dnsErrors)))
+	fmt.Fprintf(w, "blocked:     %.1f%%\n", rate(atomic.LoadInt64(&st.blocked)))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBench(t *testing.T) {
+	now := time.Now()
+	b := newBench(now)
+	for i := 1; i <= 100; i++ {
+		b.add(time.Duration(i) * time.Millisecond)
+	}
+	st := &stats{requests: 100, networkErrors: 2, dnsErrors: 1, blocked: 5}
+
+	w := &bytes.Buffer{}
+	b.write(w, st, now.Add(4*time.Second))
+	for _, want := range []string{
+		"requests:    100 in 4s\n",
+		"throughput:  25.0 requests/s\n",
+		"latency:     p50 50ms, p95 95ms, p99 99ms\n",
+		"errors:      3.0% (network 2.0%, dns 1.0%)\n",
+		"blocked:     5.0%\n",
+	} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("expected %q to contain %q", w.String(), want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// maxBodyBuffer is how much of a response body is kept in memory for
+// evidence, HAR and the checks looking at the page. The rest is only
+// streamed through the matcher of the request.
+const maxBodyBuffer = 1 << 20
+
+// bodyMatcher compares a response body with a local file while it is
+// read, either by finding the head lines of the file anywhere in it or by
+// hashing it, so bodies don't have to be held in memory.
+type bodyMatcher struct {
+	lines [][]byte
+	found []bool
+	// tail keeps the end of the data written so far, so lines crossing
+	// two writes are found.
+	tail    []byte
+	maxLine int
+
+	hash hash.Hash
+	size int64
+}
+
+// newLineMatcher finds every one of lines in a body.
+func newLineMatcher(lines []string) *bodyMatcher {
+	m := &bodyMatcher{found: make([]bool, len(lines))}
+	for _, l := range lines {
+		m.lines = append(m.lines, []byte(l))
+		if len(l) > m.maxLine {
+			m.maxLine = len(l)
+		}
+	}
+	return m
+}
+
+// newHashMatcher hashes a body to compare it with a whole file.
+func newHashMatcher() *bodyMatcher {
+	return &bodyMatcher{hash: sha256.New()}
+}
+
+func (m *bodyMatcher) Write(p []byte) (int, error) {
+	m.size += int64(len(p))
+	if m.hash != nil {
+		return m.hash.Write(p)
+	}
+	if m.matched() {
+		return len(p), nil
+	}
+
+	buf := append(m.tail, p...)
+	for i, l := range m.lines {
+		if !m.found[i] && bytes.Contains(buf, l) {
+			m.found[i] = true
+		}
+	}
+	keep := m.maxLine - 1
+	if keep < 0 {
+		keep = 0
+	}
+	if len(buf) > keep {
+		buf = buf[len(buf)-keep:]
+	}
+	m.tail = append(m.tail[:0], buf...)
+	return len(p), nil
+}
+
+// matched reports whether every line was found. Without lines, that is
+// an empty file, only an empty body matches.
+func (m *bodyMatcher) matched() bool {
+	if len(m.lines) == 0 {
+		return m.size == 0
+	}
+	for _, f := range m.found {
+		if !f {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *bodyMatcher) sum() []byte {
+	return m.hash.Sum(nil)
+}
+
+type matcherKey struct{}
+
+// withMatcher makes fetchers stream the body of the request of ctx
+// through m.
+func withMatcher(ctx context.Context, m *bodyMatcher) context.Context {
+	return context.WithValue(ctx, matcherKey{}, m)
+}
+
+// readBody reads r through the matcher of ctx, if any, and returns the
+// first maxBodyBuffer bytes of it with its full size.
+func readBody(ctx context.Context, r io.Reader) (body []byte, size int64, err error) {
+	w := io.Writer(&limitedBuffer{max: maxBodyBuffer})
+	buf := w.(*limitedBuffer)
+	if m, ok := ctx.Value(matcherKey{}).(*bodyMatcher); ok && m != nil {
+		w = io.MultiWriter(buf, m)
+	}
+	size, err = io.Copy(w, r)
+	return buf.buf.Bytes(), size, err
+}
+
+// limitedBuffer keeps the first max bytes written to it. The buffer is
+// not embedded, so that io.Copy can't bypass Write with its ReadFrom.
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.buf.Len(); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		b.buf.Write(p[:n])
+	}
+	return len(p), nil
+}
+
+// bodyLimits protect workers from response bodies that trickle in, never
+// end or decompress to far more than was sent. Zero values disable a
+// limit.
+type bodyLimits struct {
+	// idle aborts a body when no data arrives for this long.
+	idle time.Duration
+	// maxSize aborts bodies larger than this, after decompression.
+	maxSize int64
+	// maxRatio aborts compressed bodies expanding more than this many
+	// times.
+	maxRatio int64
+}
+
+var defaultBodyLimits = bodyLimits{idle: 10 * time.Second, maxRatio: 100}
+
+// minExpansionCheck is how much a compressed body may decompress to before
+// its ratio is checked, so that small, very compressible pages pass.
+const minExpansionCheck = 1 << 20
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// guardedReader enforces the size and expansion limits on a body.
+// compressed counts the bytes read off the wire when the body is
+// decompressed.
+type guardedReader struct {
+	r          io.Reader
+	limits     bodyLimits
+	n          int64
+	compressed *countingReader
+}
+
+func (g *guardedReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+	if g.limits.maxSize > 0 && g.n > g.limits.maxSize {
+		return n, &bodyError{msg: fmt.Sprintf("body larger than %d bytes", g.limits.maxSize)}
+	}
+	if g.compressed != nil && g.limits.maxRatio > 0 && g.n > minExpansionCheck && g.n > g.compressed.n*g.limits.maxRatio {
+		return n, &bodyError{msg: fmt.Sprintf("compressed body expands more than %d times", g.limits.maxRatio)}
+	}
+	return n, err
+}
+
+// bodyError is a body cut off by bodyLimits.
+type bodyError struct {
+	msg string
+	// timeout is set when the body stopped arriving.
+	timeout bool
+}
+
+func (e *bodyError) Error() string {
+	return e.msg
+}
+
+// idleReader cancels the request of a body when no data arrives for idle,
+// which unblocks the read waiting for it.
+type idleReader struct {
+	r     io.Reader
+	idle  time.Duration
+	timer *time.Timer
+	fired int32
+}
+
+func newIdleReader(r io.Reader, idle time.Duration, cancel func()) *idleReader {
+	ir := &idleReader{r: r, idle: idle}
+	ir.timer = time.AfterFunc(idle, func() {
+		atomic.StoreInt32(&ir.fired, 1)
+		cancel()
+	})
+	return ir
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if n > 0 {
+		ir.timer.Reset(ir.idle)
+	}
+	if err != nil && atomic.LoadInt32(&ir.fired) == 1 {
+		err = &bodyError{msg: fmt.Sprintf("no body data for %s", ir.idle), timeout: true}
+	}
+	return n, err
+}
+
+func (ir *idleReader) stop() {
+	ir.timer.Stop()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestBodyMatcher(t *testing.T) {
+	tests := []struct {
+		lines  []string
+		chunks []string
+		expect bool
+	}{
+		{[]string{"<?php", "echo 1;"}, []string{"<?php\necho 1;\n"}, true},
+		{[]string{"<?php", "echo 1;"}, []string{"<?php\nec", "ho 1;\n"}, true},
+		{[]string{"<?php", "echo 1;"}, []string{"<?php\n", "echo 2;\n"}, false},
+		{[]string{"abc"}, []string{"a", "b", "c"}, true},
+		{nil, nil, true},
+		{nil, []string{"x"}, false},
+	}
+	for i, tt := range tests {
+		m := newLineMatcher(tt.lines)
+		for _, c := range tt.chunks {
+			m.Write([]byte(c))
+		}
+		if m.matched() != tt.expect {
+			t.Errorf("%d: expected %v to eq %v", i, m.matched(), tt.expect)
+		}
+	}
+
+	m := newHashMatcher()
+	m.Write([]byte("ab"))
+	m.Write([]byte("c"))
+	if sum := sha256.Sum256([]byte("abc")); !bytes.Equal(m.sum(), sum[:]) {
+		t.Errorf("expected the hash of the whole body")
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	large := strings.Repeat("a", maxBodyBuffer) + "<?php secret"
+	m := newLineMatcher([]string{"<?php secret"})
+	body, size, err := readBody(withMatcher(context.Background(), m), strings.NewReader(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != maxBodyBuffer || size != int64(len(large)) {
+		t.Errorf("expected %d of %d bytes to be kept, got %d of %d", maxBodyBuffer, len(large), len(body), size)
+	}
+	if !m.matched() {
+		t.Error("expected the line after the buffer to be found")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	storageS3  = "s3"
+	storageGCS = "gcs"
+)
+
+// bucketError is the XML error document returned by S3 and GCS.
+type bucketError struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func bucketURL(storage, bucket string) string {
+	if storage == storageGCS {
+		return fmt.Sprintf("https://storage.googleapis.com/%s/", bucket)
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/", bucket)
+}
+
+// bucketEntries returns a copy of entries pointed at the public object URL
+// of bucket.
+func bucketEntries(entries []*entry, storage, bucket string) []*entry {
+	u := bucketURL(storage, bucket)
+	es := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		be := *e
+		be.URL = u
+		be.storage = storage
+		es = append(es, &be)
+	}
+	return es
+}
+
+// bucketStatus reports a non-200 object response. Anonymous requests get
+// 403 for both private and missing objects unless the bucket is publicly
+// listable, in which case missing objects are 404.
+func bucketStatus(u, status string, body []byte) error {
+	be := bucketError{}
+	xml.Unmarshal(body, &be)
+
+	switch be.Code {
+	case "NoSuchBucket":
+		return fmt.Errorf("bucket does not exist: %s", u)
+	case "NoSuchKey":
+		logrus.Infof("request: %s %s (object does not exist, bucket is publicly listable)", u, status)
+	case "AccessDenied":
+		logrus.Infof("request: %s %s (object is private or does not exist)", u, status)
+	default:
+		logrus.Warnf("request: %s %s %s", u, status, be.Code)
+	}
+	return nil
+}
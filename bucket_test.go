@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBucketEntries(t *testing.T) {
+	entries := []*entry{{Path: "./a/b.env", URL: "https://example.com"}}
+
+	for storage, expected := range map[string]string{
+		storageS3:  "https://my-bucket.s3.amazonaws.com/a/b.env",
+		storageGCS: "https://storage.googleapis.com/my-bucket/a/b.env",
+	} {
+		es := bucketEntries(entries, storage, "my-bucket")
+		u, err := urlJoin(es[0].URL, es[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != expected {
+			t.Errorf("expected %q to eq %q", u, expected)
+		}
+		if es[0].storage != storage {
+			t.Errorf("expected %q to eq %q", es[0].storage, storage)
+		}
+	}
+	if entries[0].URL != "https://example.com" {
+		t.Errorf("expected original entry to be untouched, got %q", entries[0].URL)
+	}
+}
+
+func TestBucketStatus(t *testing.T) {
+	if err := bucketStatus("https://b.s3.amazonaws.com/a", "404 Not Found", []byte(`<Error><Code>NoSuchBucket</Code></Error>`)); err == nil {
+		t.Error("expected error for missing bucket")
+	}
+	if err := bucketStatus("https://b.s3.amazonaws.com/a", "403 Forbidden", []byte(`<Error><Code>AccessDenied</Code></Error>`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScanner_bucketStatus(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.env"), []byte("SECRET=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for status, code := range map[int]string{
+		http.StatusForbidden: "AccessDenied",
+		http.StatusNotFound:  "NoSuchKey",
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", code)
+		}))
+		s := &scanner{fetchers: newFetchers(ts.Client(), 3, &tls.Config{}, nil), root: root}
+		res, err := s.check(context.Background(), &entry{Path: "./a.env", URL: ts.URL, storage: storageS3}, ts.URL+"/a.env")
+		ts.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Verdict != verdictNotPublished {
+			t.Errorf("%s: expected %q to eq %q", code, res.Verdict, verdictNotPublished)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const prioritizeSensitive = "sensitive"
+
+// prioritize orders entries by the severity their paths would be reported
+// with, most severe first, keeping the input order within a severity.
+func prioritize(entries []*entry, rules severityRules) {
+	rank := map[string]int{}
+	for i, sev := range severities {
+		rank[sev] = i
+	}
+	ranks := make(map[*entry]int, len(entries))
+	for _, e := range entries {
+		sev, _ := rules.classify(e.Path)
+		ranks[e] = rank[sev]
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return ranks[entries[i]] > ranks[entries[j]]
+	})
+}
+
+// budgetSpent reports whether the time budget of the scan ran out.
+func (s *scanner) budgetSpent() bool {
+	select {
+	case <-s.budget:
+		return true
+	default:
+		return false
+	}
+}
+
+// leave keeps es to be scanned by a later run, as the budget ran out or
+// the scan was canceled before they were done.
+func (s *scanner) leave(es []*entry) {
+	s.mu.Lock()
+	s.remaining = append(s.remaining, es...)
+	s.mu.Unlock()
+}
+
+// stateEntry is an entry of a -state file, which keeps where the path
+// was discovered too.
+type stateEntry struct {
+	*entry
+	Source string `json:"source,omitempty"`
+}
+
+func readState(path string) ([]*entry, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	entries := []*entry{}
+	sc := bufio.NewScanner(fp)
+	sc.Buffer(make([]byte, 0, initScanTokenSize), MaxScanTokenSize)
+	for i := 1; sc.Scan(); i++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		se := &stateEntry{entry: &entry{}}
+		if err := json.Unmarshal(sc.Bytes(), se); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, i, err)
+		}
+		se.entry.source = se.Source
+		entries = append(entries, se.entry)
+	}
+	return entries, sc.Err()
+}
+
+// writeState saves the entries left by a scan to path, or removes it when
+// none are left, so that the next run starts over from the input.
+func writeState(path string, entries []*entry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fp)
+	for _, e := range entries {
+		if err := enc.Encode(&stateEntry{entry: e, Source: e.source}); err != nil {
+			fp.Close()
+			return err
+		}
+	}
+	return fp.Close()
+}
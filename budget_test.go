@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrioritize(t *testing.T) {
+	entries := []*entry{{Path: "./index.php"}, {Path: "./app.js.map"}, {Path: "./db.sql"}, {Path: "./.env"}, {Path: "./about.php"}, {Path: "./backup.dump"}}
+	prioritize(entries, nil)
+
+	expected := []string{"./.env", "./db.sql", "./backup.dump", "./index.php", "./about.php", "./app.js.map"}
+	for i, e := range entries {
+		if e.Path != expected[i] {
+			t.Errorf("expected %q to eq %q", e.Path, expected[i])
+		}
+	}
+}
+
+func TestScanner_budget(t *testing.T) {
+	ctx := context.Background()
+	spent := make(chan struct{})
+	close(spent)
+	s := &scanner{gate: newGate(ctx, 2), budget: spent}
+	entries := []*entry{{Path: "./a.php"}, {Path: "./b.php"}}
+	if err := s.run(ctx, entries, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.remaining) != 2 {
+		t.Errorf("expected every path to be left, got %d", len(s.remaining))
+	}
+}
+
+func TestState(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pmr.state")
+	entries := []*entry{{Path: "./a.php", URL: "https://example.com/", source: "sitemap"}, {Path: "./b.php", Expect: 404}}
+	if err := writeState(p, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readState(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected %d to eq %d", len(got), 2)
+	}
+	if got[0].Path != "./a.php" || got[0].URL != "https://example.com/" || got[0].source != "sitemap" {
+		t.Errorf("unexpected entry %+v", got[0])
+	}
+	if got[1].Expect != 404 {
+		t.Errorf("expected %d to eq %d", got[1].Expect, 404)
+	}
+
+	if err := writeState(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readState(p); err == nil {
+		t.Error("expected the state to be removed when no paths are left")
+	}
+}
+
+func TestRun_deadlineAndState(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	for _, p := range []string{"a.php", "b.php", "c.php"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, p), []byte("<?php\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := filepath.Join(dir, "pmr.state")
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./a.php\n./b.php\n./c.php\n"), outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "-url", ts.URL, "-root", dir, "-c", "1", "-deadline", "100ms", "-state", state}); status != ExitCodeError {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeError, errStream.String())
+	}
+	left, err := readState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 3 {
+		t.Errorf("expected every path to be left, got %d", len(left))
+	}
+}
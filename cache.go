@@ -0,0 +1,73 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// responseCache keeps the most recently used responses of a scan up to a
+// total body size, so urls requested again, e.g. by case variants or
+// both schemes, are not fetched twice.
+type responseCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	lru     *list.List
+	items   map[string]*list.Element
+}
+
+type cacheItem struct {
+	key string
+	r   *Response
+}
+
+func newResponseCache(maxSize int64) *responseCache {
+	return &responseCache{maxSize: maxSize, lru: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *responseCache) get(key string) (*Response, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheItem).r, true
+}
+
+// add caches r, evicting the least recently used responses to make room.
+// Responses larger than the cache are not kept.
+func (c *responseCache) add(key string, r *Response) {
+	if c == nil {
+		return
+	}
+	n := cacheSize(key, r)
+	if n > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.size -= cacheSize(key, el.Value.(*cacheItem).r)
+		el.Value = &cacheItem{key: key, r: r}
+		c.lru.MoveToFront(el)
+	} else {
+		c.items[key] = c.lru.PushFront(&cacheItem{key: key, r: r})
+	}
+	c.size += n
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		it := el.Value.(*cacheItem)
+		c.lru.Remove(el)
+		delete(c.items, it.key)
+		c.size -= cacheSize(it.key, it.r)
+	}
+}
+
+func cacheSize(key string, r *Response) int64 {
+	return int64(len(key) + len(r.Body))
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestResponseCache(t *testing.T) {
+	c := newResponseCache(20)
+	c.add("a", &Response{Body: []byte("123456789")})
+	c.add("b", &Response{Body: []byte("123456789")})
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+	// a was used last, so b is evicted
+	c.add("c", &Response{Body: []byte("123")})
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+	if c.size != 14 {
+		t.Errorf("expected %d to eq %d", c.size, 14)
+	}
+
+	c.add("d", &Response{Body: make([]byte, 20)})
+	if _, ok := c.get("d"); ok {
+		t.Error("expected a response larger than the cache not to be kept")
+	}
+
+	var nilCache *responseCache
+	nilCache.add("a", &Response{})
+	if _, ok := nilCache.get("a"); ok {
+		t.Error("expected a nil cache to be empty")
+	}
+}
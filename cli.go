@@ -1,20 +1,22 @@
 package main
 
 import (
-	"bufio"
-	"crypto/tls"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
-	"net/url"
+	"math/rand"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,48 +26,261 @@ const (
 	ExitCodeError int = 1 + iota
 )
 
-const (
-	initScanTokenSize int = 1024 * 4
-	MaxScanTokenSize  int = 1024 * 64
-)
-
 // CLI is the command line object
 type CLI struct {
+	// inStream is the stdin to read paths from.
+	inStream io.Reader
 	// outStream and errStream are the stdout and stderr
 	// to write message from the CLI.
 	outStream, errStream io.Writer
+	// flagSets receives the flags of a command instead of parsing them,
+	// when they are collected for completion.
+	flagSets func(*flag.FlagSet)
 }
 
-// Run invokes the CLI with the given arguments.
+// Run invokes the CLI with the given arguments. Without a known
+// subcommand the arguments are taken as scan flags, as before subcommands
+// existed.
 func (cli *CLI) Run(args []string) int {
+	if len(args) > 1 {
+		switch args[1] {
+		case "scan":
+			return cli.runScan(args[1:])
+		case "report":
+			return cli.runReport(args[1:])
+		case "baseline":
+			return cli.runBaseline(args[1:])
+		case "serve":
+			return cli.runServe(args[1:])
+		case "prune":
+			return cli.runPrune(args[1:])
+		case "replay":
+			return cli.runReplay(args[1:])
+		case "self-update":
+			return cli.runSelfUpdate(args[1:])
+		case "completion":
+			return cli.runCompletion(args[1:])
+		case "version":
+			fmt.Fprintf(cli.errStream, "%s version %s\n", Name, Version)
+			return ExitCodeOK
+		}
+	}
+	return cli.runScan(args)
+}
+
+// runScan checks the given paths against the target.
+func (cli *CLI) runScan(args []string) int {
 	var (
 		timeout     int
+		perHost     int
+		deadline    time.Duration
 		concurrency int
 		url         string
 		insecure    bool
+		tlsMin      string
+		tlsMax      string
+		ciphers     string
+		http1       bool
+		http2       bool
 		skipErrors  bool
+		skipNetwork bool
+		skipDNS     bool
+		skipLocal   bool
+		probeLocal  bool
+		followLinks bool
+		skipSpecial bool
+		maxSize     string
+		cacheMax    string
+		checkType   bool
+		checkDirs   bool
+		checkVCS    bool
+		checkMaps   bool
+		wafBackoff  time.Duration
+		benchmark   bool
+		pprofListen string
+		cpuProfile  string
+		memProfile  string
+		largeFiles  string
+		exts        string
+		skipExts    string
+		root        string
+		rawPath     bool
+		caseVariant bool
+		appendQuery string
+		stripQuery  bool
+		evidenceDir string
+		diffLines   int
+		harPath     string
+		execCmd     string
+		dbPath      string
+		esURL       string
+		esIndex     string
+		configPath  string
+		notifyEmail string
+		profileName string
+		format      string
+		inputFormat string
+		sitemapURL  string
+		robotsURL   string
+		archivePath string
+		openAPIPath string
+		image       string
+		imageRoot   string
+		s3Bucket    string
+		gcsBucket   string
+		bothSchemes bool
+		reportTLS   bool
+		tlsWarnDays int
+		dryRun      bool
+		noColor     bool
+		noProgress  bool
+		useTUI      bool
+		sample      string
+		portList    string
+		genericMin  int
+		budget      time.Duration
+		priority    string
+		stateFile   string
+		errorTitles string
+		confirm     int64
+		output      string
+		baseline    string
+		publicList  string
+		rulesPath   string
+		crawl       bool
+		maxDepth    int
+		watchDir    string
+		workers     string
+		workerToken string
+		compare     string
+		manifest    string
+		fetcherCmds string
+		capture     string
+		idleTimeout time.Duration
+		maxBody     string
+		maxRatio    int64
+		dnsServer   string
+		dnsTTL      time.Duration
+		connTimeout time.Duration
+		tlsTimeout  time.Duration
+		hdrTimeout  time.Duration
+		matcherCmds string
+		credHelper  string
 
 		version bool
 	)
 
 	// Define option flag parse
-	flags := flag.NewFlagSet(Name, flag.ContinueOnError)
+	flags := flag.NewFlagSet(Name+" scan", flag.ContinueOnError)
 	flags.SetOutput(cli.errStream)
 
 	flags.IntVar(&concurrency, "concurrency", 5, "request concurrency")
 	flags.IntVar(&concurrency, "c", 5, "request concurrency(Short)")
-	flags.IntVar(&timeout, "timeout", 3, "request timeout sec")
+	flags.IntVar(&perHost, "per-host-concurrency", 0, "request concurrency per host(0 means only -concurrency applies)")
+	flags.IntVar(&timeout, "request-timeout", 3, "request timeout sec")
+	flags.IntVar(&timeout, "timeout", 3, "request timeout sec(Deprecated: use -request-timeout)")
 	flags.IntVar(&timeout, "t", 3, "request timeout sec(Short)")
-	flags.StringVar(&url, "url", "", "url")
+	flags.DurationVar(&connTimeout, "connect-timeout", 0, "connect timeout, e.g. 2s(0 means only -request-timeout applies)")
+	flags.DurationVar(&tlsTimeout, "tls-handshake-timeout", 0, "TLS handshake timeout, e.g. 10s(0 means only -request-timeout applies)")
+	flags.DurationVar(&hdrTimeout, "response-header-timeout", 0, "how long to wait for response headers once the request is sent(0 means only -request-timeout applies)")
+	flags.DurationVar(&wafBackoff, "waf-backoff", 0, "when a WAF blocks a request, halve the concurrency and pause for this long, e.g. 30s")
+	flags.DurationVar(&idleTimeout, "body-idle-timeout", defaultBodyLimits.idle, "abort a response body when no data arrives for this long(0 disables)")
+	flags.StringVar(&maxBody, "max-body-size", "", "abort response bodies larger than this after decompression, e.g. 1G")
+	flags.Int64Var(&maxRatio, "max-expansion", defaultBodyLimits.maxRatio, "abort gzip response bodies decompressing to more than this many times their size(0 leaves decompression to Go)")
+	flags.DurationVar(&deadline, "deadline", 0, "overall scan deadline, e.g. 30m(0 means no limit)")
+	flags.StringVar(&url, "url", "", "url, or a template with {path}, {dir}, {base} and {host}")
 	flags.StringVar(&url, "u", "", "url(Short)")
+	flags.StringVar(&dnsServer, "dns-server", "", "resolve host names with this DNS server instead of the system resolver, e.g. 1.1.1.1:53")
+	flags.DurationVar(&dnsTTL, "dns-cache-ttl", time.Minute, "how long resolved addresses are reused(0 disables the cache)")
 	flags.BoolVar(&insecure, "insecure", false, "Allow connections to SSL sites without certs")
 	flags.BoolVar(&insecure, "k", false, "Allow connections to SSL sites without certs(Short)")
-	flags.BoolVar(&skipErrors, "skip-errors", false, "Skip errors if HTTP GET request fails")
+	flags.StringVar(&tlsMin, "tls-min", "", "minimum TLS version(1.0, 1.1, 1.2, 1.3)")
+	flags.StringVar(&tlsMax, "tls-max", "", "maximum TLS version(1.0, 1.1, 1.2, 1.3)")
+	flags.BoolVar(&http1, "http1", false, "force HTTP/1.1")
+	flags.BoolVar(&http2, "http2", false, "force HTTP/2 for https urls")
+	flags.StringVar(&ciphers, "ciphers", "", "comma separated TLS 1.0-1.2 cipher suites, e.g. TLS_RSA_WITH_AES_128_CBC_SHA")
+	flags.BoolVar(&skipErrors, "skip-errors", false, "Skip all errors below")
+	flags.BoolVar(&skipNetwork, "skip-network-errors", false, "Skip errors if HTTP GET request fails")
+	flags.BoolVar(&skipDNS, "skip-dns-errors", false, "Skip errors if a host name can't be resolved")
+	flags.BoolVar(&skipLocal, "skip-local-errors", false, "Skip errors if a local file can't be read")
+	flags.BoolVar(&followLinks, "follow-symlinks", true, "read the target of local symbolic links(-follow-symlinks=false skips them)")
+	flags.BoolVar(&skipSpecial, "skip-special", false, "skip local FIFOs, devices, sockets and dangling symbolic links")
+	flags.BoolVar(&rawPath, "raw-path", false, "use paths as url references without escaping, e.g. to keep a query string")
+	flags.BoolVar(&caseVariant, "case-variants", false, "also check the lower and upper case variants of every path")
+	flags.StringVar(&appendQuery, "append-query", "", "add this to the query string of every url, e.g. v=123")
+	flags.BoolVar(&stripQuery, "strip-query", false, "drop the query string of the url and raw paths")
+	flags.StringVar(&root, "root", "", "directory to read relative local paths from instead of the working directory")
+	flags.StringVar(&exts, "ext", "", "only check paths with these comma separated extensions, e.g. php,env,sql")
+	flags.StringVar(&skipExts, "skip-ext", "", "do not check paths with these comma separated extensions, e.g. png,jpg,woff2")
+	flags.StringVar(&maxSize, "max-local-size", "", "size of local files above which -large-files applies, e.g. 100M")
+	flags.BoolVar(&checkMaps, "sourcemap-check", false, "also report the .map of every .js and .css path that is served")
+	flags.BoolVar(&checkVCS, "vcs-check", false, "also report .git, .svn and .hg metadata served at the top of the url and its top level directories")
+	flags.BoolVar(&checkDirs, "check-dirs", false, "also report parent directories of the paths that answer with a directory listing")
+	flags.BoolVar(&checkType, "check-content-type", false, "report scripts served as source and warn about content types that don't match the extension")
+	flags.StringVar(&capture, "capture-headers", "Server,X-Powered-By,Cache-Control,Content-Disposition", "comma separated response headers kept in the results of -format ndjson")
+	flags.StringVar(&fetcherCmds, "plugin-fetcher", "", "comma separated scheme=command pairs of external fetchers for other url schemes")
+	flags.StringVar(&credHelper, "credential-helper", "", "command printing netrc style login, password or token of the host on its stdin")
+	flags.StringVar(&matcherCmds, "plugin-matcher", "", "comma separated kind=command pairs of external content checks of every response answering 200")
+	flags.StringVar(&cacheMax, "cache-size", "32M", "total body size of recent responses kept to avoid requesting a url twice(0 disables)")
+	flags.StringVar(&largeFiles, "large-files", "skip", "what to do with local files over -max-local-size(skip, hash)")
+	flags.BoolVar(&probeLocal, "probe-unreadable", false, "Skip local files that can't be read, but report them if the url answers 200")
+	flags.StringVar(&inputFormat, "input-format", inputFormatPlain, "input format(plain, ndjson)")
+	flags.StringVar(&sitemapURL, "from-sitemap", "", "read paths from the sitemap.xml at this url instead of stdin")
+	flags.StringVar(&robotsURL, "from-robots", "", "read Disallow paths from the robots.txt at this url instead of stdin")
+	flags.StringVar(&openAPIPath, "from-openapi", "", "read static file routes from this OpenAPI file instead of stdin")
+	flags.StringVar(&archivePath, "archive", "", "read paths and file heads from a zip or tar(.gz) archive instead of stdin")
+	flags.StringVar(&image, "image", "", "read paths and file heads from a docker image or a saved image tarball instead of stdin")
+	flags.StringVar(&imageRoot, "image-root", "/var/www", "directory inside the image that is served at the url")
+	flags.StringVar(&s3Bucket, "s3-bucket", "", "check whether paths are publicly readable in this S3 bucket")
+	flags.StringVar(&gcsBucket, "gcs-bucket", "", "check whether paths are publicly readable in this GCS bucket")
+	flags.BoolVar(&bothSchemes, "both-schemes", false, "retry paths not published over https with plain http on the same host")
+	flags.BoolVar(&reportTLS, "tls-report", false, "print the certificate chain of each host after the scan")
+	flags.IntVar(&tlsWarnDays, "tls-warn-days", 30, "warn about certificates expiring within this many days")
+	flags.BoolVar(&noColor, "no-color", false, "disable colored output on terminals")
+	flags.BoolVar(&noProgress, "no-progress", false, "disable the progress bar on terminals")
+	flags.IntVar(&genericMin, "generic-pages", 0, "downgrade findings served with a page this many urls answer with to info, e.g. 10(0 disables it)")
+	flags.StringVar(&errorTitles, "error-titles", defaultErrorTitles, "comma separated title words of error pages to downgrade findings served with to info, with -generic-pages")
+	flags.DurationVar(&budget, "budget", 0, "stop starting paths once the scan ran this long, e.g. 10m, and leave the rest to -state")
+	flags.StringVar(&priority, "prioritize", "", "scan paths in this order, sensitive: by the severity of their rules, most severe first")
+	flags.StringVar(&stateFile, "state", "", "file to save the paths a -budget scan didn't get to, which the next run scans instead of the input")
+	flags.StringVar(&portList, "ports", "", "comma separated ports to request every path on, e.g. 80,443,8080,8443 or https:9443")
+	flags.StringVar(&sample, "sample", "", "scan only a random subset of the paths, e.g. 1% or 100")
+	flags.Int64Var(&confirm, "confirm", 0, "pause after this many findings and ask on the terminal whether to continue")
+	flags.BoolVar(&useTUI, "tui", false, "run the scan in an interactive terminal UI")
+	flags.StringVar(&output, "output", "", "save findings to this file for the report and baseline commands")
+	flags.StringVar(&output, "o", "", "save findings to this file(Short)")
+	flags.StringVar(&format, "format", formatText, "output format on stdout(text, github, ndjson for the result of every check)")
+	flags.IntVar(&diffLines, "diff-lines", 0, "add a unified diff of up to this many lines between the local file and the response to published findings")
+	flags.StringVar(&evidenceDir, "evidence-dir", "", "save the request and response of every published file to this directory")
+	flags.StringVar(&harPath, "har", "", "record every request and response to this HAR file")
+	flags.StringVar(&execCmd, "exec", "", "run this shell command for every finding, e.g. 'notify {path} {url}'; the finding is passed as JSON on stdin")
+	flags.StringVar(&dbPath, "db", "", "record the scan and its findings in this SQLite database for report -db")
+	flags.StringVar(&esURL, "es-url", "", "bulk index findings into the Elasticsearch or OpenSearch at this url")
+	flags.StringVar(&esIndex, "es-index", "pmr", "index for -es-url")
+	flags.StringVar(&configPath, "config", "", "config file with smtp settings for -notify-email and profiles")
+	flags.StringVar(&profileName, "profile", "", "apply the settings of this profile of -config, flags given take precedence")
+	flags.StringVar(&notifyEmail, "notify-email", "", "comma separated addresses to mail a digest to when the scan has findings")
+	flags.StringVar(&baseline, "baseline", "", "do not report findings recorded in this baseline file")
+	flags.StringVar(&rulesPath, "rules", "", "YAML file of path globs and their severity, applied before the built-in rules")
+	flags.StringVar(&publicList, "public-list", "", "never report paths matching the paths or globs in this file, e.g. robots.txt")
+	flags.BoolVar(&benchmark, "bench", false, "print the throughput, latency percentiles and error rates of the scan")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the resolved url of every path without requesting it")
+	flags.BoolVar(&crawl, "crawl", false, "crawl the url and report served files that are not in the input paths")
+	flags.IntVar(&maxDepth, "max-depth", 3, "max link depth to follow in crawl mode")
+	flags.StringVar(&workers, "workers", "", "comma separated urls of pmr serve workers to shard the scan across")
+	flags.StringVar(&workerToken, "worker-token", os.Getenv(tokenEnv), "bearer token of the -workers, $"+tokenEnv+" by default")
+	flags.StringVar(&compare, "compare-targets", "", "comma separated urls to request every path from and report where they differ, e.g. https://staging.example.com,https://example.com")
+	flags.StringVar(&manifest, "manifest", "", "verify that every file of this sha256sum file, e.g. SHA256SUMS, is served with its checksum")
+	flags.StringVar(&watchDir, "watch", "", "watch this directory and check files as soon as they are created or modified")
+
+	flags.StringVar(&pprofListen, "pprof-listen", "", "serve net/http/pprof on this address, e.g. localhost:6060")
+	flags.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the scan to this file")
+	flags.StringVar(&memProfile, "memprofile", "", "write a heap profile to this file when the scan is done")
 
 	flags.BoolVar(&version, "version", false, "Print version information and quit.")
 
 	// Parse commandline flag
-	if err := flags.Parse(args[1:]); err != nil {
+	if err := cli.parse(flags, args[1:]); err != nil {
 		return ExitCodeError
 	}
 
@@ -75,125 +290,629 @@ func (cli *CLI) Run(args []string) int {
 		return ExitCodeOK
 	}
 
-	body, err := ioutil.ReadAll(os.Stdin)
+	setupLogger(cli.errStream, noColor)
+	var conf *config
+	if configPath != "" {
+		c, err := loadConfig(configPath)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		conf = c
+	}
+	var profile *profileConfig
+	if profileName != "" {
+		if conf == nil {
+			logrus.Fatal("profile needs -config")
+		}
+		if profile = conf.Profiles[profileName]; profile == nil {
+			logrus.Fatalf("%s: unknown profile %s", configPath, profileName)
+		}
+		if err := profile.apply(flags); err != nil {
+			logrus.Fatalf("%s: profile %s: %s", configPath, profileName, err)
+		}
+	}
+	started := time.Now()
+	stopProfiling, err := startProfiling(pprofListen, cpuProfile, memProfile)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	lines := strings.Split(string(body), "\n")
+	defer stopProfiling()
+	// internationalized hosts are requested by their punycode form and
+	// shown in their Unicode form in findings and results
+	if url, err = asciiURL(url); err != nil {
+		logrus.Fatal(err)
+	}
+	sinks := results{output: output, har: harPath, db: dbPath, esURL: esURL, esIndex: esIndex}
+	switch format {
+	case formatText:
+	case formatGitHub:
+		sinks.github = cli.outStream
+	case formatNDJSON:
+	default:
+		logrus.Fatalf("unknown format %s", format)
+	}
+	if notifyEmail != "" {
+		if conf == nil {
+			logrus.Fatal("notify-email needs smtp settings in -config")
+		}
+		if conf.SMTP == nil {
+			logrus.Fatalf("%s: notify-email needs smtp settings", configPath)
+		}
+		sinks.smtp = conf.SMTP
+		sinks.email = strings.Split(notifyEmail, ",")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	tlsConfig, err := newTLSConfig(insecure, tlsMin, tlsMax, ciphers)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	proto := protoAuto
+	switch {
+	case http1 && http2:
+		logrus.Fatal("http1 and http2 are exclusive")
+	case http1:
+		proto = protoHTTP1
+	case http2:
+		proto = protoHTTP2
+	}
+	dns, err := newDNSCache(dnsServer, dnsTTL, &net.Dialer{Timeout: connTimeout})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	client := newHTTPClient(timeout, tlsConfig, proto, dns.DialContext, transportTimeouts{tlsHandshake: tlsTimeout, responseHeader: hdrTimeout})
+
+	var (
+		entries []*entry
+		sums    map[string][]byte
+		resumed bool
+	)
+	if stateFile != "" {
+		if s3Bucket != "" || gcsBucket != "" {
+			logrus.Fatal("state can't be used with the buckets")
+		}
+		if entries, err = readState(stateFile); err == nil {
+			resumed = true
+			logrus.Infof("resuming %d paths left in %s", len(entries), stateFile)
+		} else if !os.IsNotExist(err) {
+			logrus.Fatal(err)
+		}
+	}
+	if resumed {
+		// the state holds what the last run left of the input
+	} else if manifest != "" {
+		if url == "" {
+			logrus.Fatal("manifest needs the url")
+		}
+		if entries, sums, err = manifestEntries(manifest, url); err != nil {
+			logrus.Fatal(err)
+		}
+	} else if sitemapURL != "" || robotsURL != "" || openAPIPath != "" || archivePath != "" || image != "" {
+		if archivePath != "" {
+			es, err := archiveEntries(archivePath, url)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			entries = append(entries, es...)
+		}
+		if image != "" {
+			es, err := imageEntries(image, imageRoot, url)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			entries = append(entries, es...)
+		}
+		if sitemapURL != "" {
+			es, err := sitemapEntries(ctx, client, sitemapURL, url)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			entries = append(entries, es...)
+		}
+		if robotsURL != "" {
+			es, err := robotsEntries(ctx, client, robotsURL, url)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			entries = append(entries, es...)
+		}
+		if openAPIPath != "" {
+			es, err := openAPIEntries(openAPIPath, url)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			entries = append(entries, es...)
+		}
+	} else if watchDir == "" {
+		body, err := ioutil.ReadAll(cli.inStream)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		entries, err = parseEntries(body, inputFormat, url)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
 
-	c := make(chan bool, concurrency)
-	eg := errgroup.Group{}
-	for _, l := range lines {
-		l := l
-		c := c
-		if l == "" || l == "\n" {
-			continue
+	extensions := newExtFilter(exts, skipExts)
+	entries = extensions.filter(entries)
+	if profile != nil {
+		profile.setHeaders(entries)
+	}
+	if sample != "" {
+		total := len(entries)
+		if entries, err = sampleEntries(entries, sample, rand.New(rand.NewSource(time.Now().UnixNano()))); err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("sampled %d of %d paths", len(entries), total)
+	}
+
+	if s3Bucket != "" || gcsBucket != "" {
+		var es []*entry
+		if s3Bucket != "" {
+			es = append(es, bucketEntries(entries, storageS3, s3Bucket)...)
+		}
+		if gcsBucket != "" {
+			es = append(es, bucketEntries(entries, storageGCS, gcsBucket)...)
 		}
-		c <- true
-		eg.Go(func() error {
-			defer func() { <-c }()
-			return request(url, timeout, insecure, l, skipErrors)
-		})
+		entries = es
 	}
-	if err := eg.Wait(); err != nil {
+
+	if root != "" {
+		if root, err = filepath.Abs(root); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	local := localPolicy{skipSymlinks: !followLinks, skipSpecial: skipSpecial}
+	if maxSize != "" {
+		if local.maxSize, err = parseSize(maxSize); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	switch largeFiles {
+	case "skip":
+	case "hash":
+		local.hashLarge = true
+	default:
+		logrus.Fatalf("unknown -large-files: %s", largeFiles)
+	}
+	limits := bodyLimits{idle: idleTimeout, maxRatio: maxRatio}
+	if maxBody != "" {
+		if limits.maxSize, err = parseSize(maxBody); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	var cache *responseCache
+	if n, err := parseSize(cacheMax); err != nil {
 		logrus.Fatal(err)
+	} else if n > 0 {
+		cache = newResponseCache(n)
 	}
-	return ExitCodeOK
-}
 
-func request(url string, timeout int, insecure bool, filePath string, skipErrors bool) error {
-	u, err := urlJoin(url, filePath)
+	fetcherPluginCmds, err := parsePlugins(fetcherCmds)
 	if err != nil {
-		return err
+		logrus.Fatal(err)
 	}
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	matcherPluginCmds, err := parsePlugins(matcherCmds)
+	if err != nil {
+		logrus.Fatal(err)
 	}
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(timeout) * time.Second,
+	for scheme, cmd := range fetcherPluginCmds {
+		registerFetcher(scheme, &execFetcher{command: cmd})
 	}
-	req, err := http.NewRequest("GET", u, nil)
-	if err != nil {
-		return err
+	for kind, cmd := range matcherPluginCmds {
+		registerMatcher(kind, &execMatcher{command: cmd})
 	}
-	ua := fmt.Sprintf("%s/%s", "PyamaMultiRequest", Version)
-	req.Header.Set("User-Agent", ua)
 
-	r, err := client.Do(req)
-	if err != nil {
-		if skipErrors {
+	var report *tlsReport
+	if reportTLS {
+		report = newTLSReport(tlsWarnDays)
+	}
+	fs := newFetchers(client, timeout, tlsConfig, report)
+	fs.setBodyLimits(limits)
+	fs.setDial(dns.DialContext)
+	if credHelper != "" {
+		fs.setCredentials(newCredentialHelper(credHelper))
+	}
+	s := &scanner{
+		fetchers: fs,
+		skip: skipPolicy{
+			network: skipErrors || skipNetwork,
+			dns:     skipErrors || skipDNS,
+			local:   skipErrors || skipLocal || probeLocal,
+		},
+		probeUnreadable: probeLocal,
+		local:           local,
+		root:            root,
+		rawPath:         rawPath,
+		caseVariants:    caseVariant,
+		query:           queryRule{strip: stripQuery, append: appendQuery},
+		evidenceDir:     evidenceDir,
+		diffLines:       diffLines,
+		cache:           cache,
+		contentType:     checkType,
+		matchers:        matcherPlugins,
+		wafBackoff:      wafBackoff,
+		bothSchemes:     bothSchemes,
+		perHost:         perHost,
+		exec:            execCmd,
+	}
+	if harPath != "" {
+		s.har = newHARRecorder()
+	}
+	if baseline != "" {
+		s.baseline, err = readBaseline(baseline)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if format == formatNDJSON {
+		s.results = &resultWriter{w: cli.outStream}
+		if capture != "" {
+			s.captureHeaders = strings.Split(capture, ",")
+		}
+	}
+	if publicList != "" {
+		s.public, err = readPublicList(publicList)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if rulesPath != "" {
+		s.rules, err = readSeverityRules(rulesPath)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	s.generic = newGenericPages(genericMin, errorTitles)
+	switch priority {
+	case "":
+	case prioritizeSensitive:
+		prioritize(entries, s.rules)
+	default:
+		logrus.Fatalf("unknown prioritize %s, expected %s", priority, prioritizeSensitive)
+	}
+	if portList != "" {
+		if s.ports, err = parsePorts(portList); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	if dryRun {
+		for _, e := range entries {
+			urls, err := s.resolveAll(e)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			for _, u := range urls {
+				pus, err := portURLs(u, s.ports)
+				if err != nil {
+					logrus.Fatal(err)
+				}
+				for _, pu := range pus {
+					fmt.Fprintln(cli.outStream, pu)
+				}
+			}
+		}
+		return ExitCodeOK
+	}
+
+	if crawl {
+		if isTemplate(url) {
+			logrus.Fatal("crawl needs a plain url, not a template")
+		}
+		cr, err := newCrawler(client, url, maxDepth, concurrency)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		served, err := cr.crawl(ctx)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		unexpected, err := unexpectedPaths(served, entries)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		for _, p := range unexpected {
+			u, err := urlJoin(url, p)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			f := &finding{Kind: findingUnexpected, Path: p, URL: u, Time: time.Now()}
+			if s.report(f) {
+				findingLog(f).Warnf("This file is served but not in the publish set %s", p)
+			}
+		}
+		sinks.save(s, url, started)
+		if interrupted(ctx, deadline) {
+			return ExitCodeError
+		}
+		return ExitCodeOK
+	}
+
+	if workers != "" {
+		if s3Bucket != "" || gcsBucket != "" {
+			logrus.Fatal("workers can't check buckets")
+		}
+		if checkDirs || checkVCS || checkMaps {
+			logrus.Fatal("workers can't run -check-dirs, -vcs-check or -sourcemap-check")
+		}
+		if len(s.ports) > 0 {
+			logrus.Fatal("workers can't run -ports")
+		}
+		c := newCoordinator(client, strings.Split(workers, ","), concurrency)
+		c.token = workerToken
+		err := c.scan(ctx, s, entries)
+		logrus.Info(s.stats.summary())
+		sinks.save(s, url, started)
+		if interrupted(ctx, deadline) {
+			return ExitCodeError
+		}
+		if err != nil {
 			logrus.Error(err)
-			return nil
-		} else {
-			return err
+			return ExitCodeError
 		}
+		return ExitCodeOK
 	}
 
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return err
+	s.gate = newGate(ctx, concurrency)
+	if benchmark {
+		s.bench = newBench(time.Now())
 	}
 
-	st := fmt.Sprintf("request: %s %s", u, r.Status)
-	if r.StatusCode != http.StatusOK &&
-		r.StatusCode != http.StatusNotFound &&
-		r.StatusCode != http.StatusForbidden {
-		logrus.Warnf(st)
-		return nil
-	} else {
-		logrus.Infof(st)
+	if compare != "" {
+		targets := strings.Split(compare, ",")
+		for i, t := range targets {
+			if targets[i], err = asciiURL(t); err != nil {
+				logrus.Fatal(err)
+			}
+		}
+		if len(targets) < 2 {
+			logrus.Fatal("compare-targets needs at least two urls")
+		}
+		if s3Bucket != "" || gcsBucket != "" {
+			logrus.Fatal("compare-targets can't check buckets")
+		}
+		err := s.compareTargets(ctx, entries, targets)
+		logrus.Info(s.stats.summary())
+		sinks.save(s, targets[0], started)
+		if interrupted(ctx, deadline) {
+			return ExitCodeError
+		}
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		return ExitCodeOK
 	}
-	lines, err := getFileHead(filePath)
-	if err != nil {
-		return err
+
+	if manifest != "" {
+		if s3Bucket != "" || gcsBucket != "" {
+			logrus.Fatal("manifest can't check buckets")
+		}
+		failed, err := s.verifyManifest(ctx, entries, sums)
+		logrus.Info(s.stats.summary())
+		sinks.save(s, url, started)
+		if interrupted(ctx, deadline) {
+			return ExitCodeError
+		}
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		if failed > 0 {
+			logrus.Errorf("%d files don't match %s", failed, manifest)
+			return ExitCodeError
+		}
+		return ExitCodeOK
 	}
 
-	if len(lines) == 0 && len(body) > 0 {
-		return nil
+	if watchDir != "" {
+		w, err := newWatcher(watchDir, url)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		w.extensions = extensions
+		w.root = s.root
+		if profile != nil {
+			w.headers = profile.Headers
+		}
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			cancel()
+		}()
+
+		logrus.Infof("watching %s", watchDir)
+		w.run(ctx, s)
+		logrus.Info(s.stats.summary())
+		sinks.save(s, url, started)
+		return ExitCodeOK
 	}
 
-	for _, l := range lines {
-		if strings.Index(string(body), l) < 0 {
-			return nil
+	var (
+		pb *progress
+		ui *tui
+	)
+	if confirm > 0 {
+		if useTUI {
+			logrus.Fatal("confirm can't be used with tui")
+		}
+		// stdin may hold the paths, so the answer is read from the terminal
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			logrus.Fatalf("confirm needs a terminal: %s", err)
+		}
+		defer tty.Close()
+		s.confirm = &confirmer{after: confirm, in: tty, out: tty, cancel: cancel}
+	}
+	if useTUI {
+		ui, err = newTUI(s.gate, &s.stats, len(entries), cancel)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.SetFormatter(&consoleFormatter{})
+		logrus.SetOutput(ui)
+		ui.run()
+		s.track = func(e *entry) func() {
+			ui.begin(e.Path)
+			return func() { ui.end(e.Path) }
+		}
+	} else if !noProgress && isTerminal(cli.errStream) {
+		pb = newProgress(cli.errStream, len(entries), &s.stats)
+		logrus.SetOutput(pb)
+		pb.run()
+		s.track = func(e *entry) func() {
+			return pb.increment
 		}
 	}
-	logrus.Warnf("This file is published %s", filePath)
-	return nil
-}
 
-func urlJoin(base, path string) (string, error) {
-	u, err := url.Parse(path)
-	if err != nil {
-		return "", err
+	if budget > 0 {
+		spent := make(chan struct{})
+		timer := time.AfterFunc(budget-time.Since(started), func() { close(spent) })
+		defer timer.Stop()
+		s.budget = spent
+	}
+	err = s.scan(ctx, entries)
+	if err == nil && s.budgetSpent() && len(s.remaining) > 0 {
+		// the other checks request paths of the whole input, which the
+		// next run does
+		logrus.Warnf("budget %s spent, %d paths were not scanned", budget, len(s.remaining))
+		checkDirs, checkVCS, checkMaps = false, false, false
+	}
+	// a canceled scan saves the paths it didn't get to as well
+	if stateFile != "" && (err == nil || ctx.Err() != nil) {
+		if err := writeState(stateFile, s.remaining); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if err == nil && checkDirs {
+		err = s.checkListings(ctx, entries)
+	}
+	if err == nil && checkVCS {
+		err = s.checkVCS(ctx, entries)
+	}
+	if err == nil && checkMaps {
+		err = s.checkSourceMaps(ctx, entries)
+	}
+	if pb != nil {
+		pb.finish()
+		logrus.SetOutput(cli.errStream)
+	}
+	if ui != nil {
+		findings := ui.finish()
+		setupLogger(cli.errStream, noColor)
+		for _, f := range findings {
+			fmt.Fprintln(cli.errStream, f)
+		}
+	}
+	logrus.Info(s.stats.summary())
+	if s.bench != nil {
+		s.bench.write(cli.outStream, &s.stats, time.Now())
+	}
+	if n := s.generic.downgradeLate(); n > 0 {
+		logrus.Infof("%d findings are served with generic pages, downgraded to %s", n, severityInfo)
+	}
+	// saved before checking errors so that failed scans can be inspected
+	results{har: sinks.har}.save(s, url, started)
+	// returned rather than exiting, so that the deferred profiles and
+	// terminal are closed
+	if interrupted(ctx, deadline) {
+		return ExitCodeError
 	}
-	pb, err := url.Parse(base)
 	if err != nil {
-		return "", err
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	if report != nil {
+		report.write(cli.outStream, time.Now())
 	}
-	return pb.ResolveReference(u).String(), nil
+	sinks.har = ""
+	sinks.save(s, url, started)
+	if n := atomic.LoadInt64(&s.stats.unmet); n > 0 {
+		logrus.Errorf("%d urls didn't answer with their expected status", n)
+		return ExitCodeError
+	}
+	return ExitCodeOK
 }
 
-func getFileHead(path string) ([]string, error) {
-	fp, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// interrupted logs why ctx cut the scan short, and returns whether it did.
+// Checks not started by then are missing from its outcome, so it must not
+// pass.
+func interrupted(ctx context.Context, deadline time.Duration) bool {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		logrus.Errorf("scan deadline %s exceeded", deadline)
+		return true
+	case context.Canceled:
+		logrus.Warn("scan aborted")
+		return true
 	}
+	return false
+}
 
-	cnt := 0
-	lines := []string{}
+// results are where the outcome of a scan is saved once it is done.
+type results struct {
+	output  string
+	har     string
+	db      string
+	esURL   string
+	esIndex string
+	email   []string
+	smtp    *smtpConfig
+	github  io.Writer
+}
 
-	defer fp.Close()
-	scanner := bufio.NewScanner(fp)
-	buf := make([]byte, 0, initScanTokenSize)
-	scanner.Buffer(buf, MaxScanTokenSize)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		cnt++
-		if cnt > 10 {
-			break
+// save writes the findings of s to the configured files and services.
+func (rs results) save(s *scanner, target string, started time.Time) {
+	target = displayURL(target)
+	if rs.output != "" {
+		if err := writeFindingsFile(rs.output, s.findings); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if rs.har != "" {
+		if err := s.har.writeFile(rs.har); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if rs.db != "" {
+		db, err := openResultDB(rs.db)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.saveScan(target, started, time.Now(), &s.stats, s.findings); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if rs.esURL != "" {
+		if err := bulkIndex(rs.esURL, rs.esIndex, target, started, s.findings); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if len(rs.email) > 0 && len(s.findings) > 0 {
+		report := rs.output
+		if report != "" {
+			if abs, err := filepath.Abs(report); err == nil {
+				report = abs
+			}
+		}
+		if err := sendDigest(rs.smtp, rs.email, target, s.findings, report); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if rs.github != nil {
+		writeAnnotations(rs.github, s.findings)
+		if err := setGitHubOutput("findings", strconv.Itoa(len(s.findings))); err != nil {
+			logrus.Fatal(err)
 		}
 	}
-	return lines, nil
 }
@@ -3,6 +3,11 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -31,3 +36,175 @@ func TestRun_ownerFlag(t *testing.T) {
 	status := cli.Run(args)
 	_ = status
 }
+
+func TestRun_dryRunFlag(t *testing.T) {
+	inStream := strings.NewReader("./index.php\n./admin/.env\n")
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: inStream, outStream: outStream, errStream: errStream}
+	args := strings.Split("./pmr -dry-run -url https://example.com/app/", " ")
+
+	status := cli.Run(args)
+	if status != ExitCodeOK {
+		t.Errorf("expected %d to eq %d", status, ExitCodeOK)
+	}
+
+	expected := "https://example.com/app/index.php\nhttps://example.com/app/admin/.env\n"
+	if outStream.String() != expected {
+		t.Errorf("expected %q to eq %q", outStream.String(), expected)
+	}
+}
+
+func TestRun_profileFlag(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pmr.yml")
+	ioutil.WriteFile(p, []byte(`profiles:
+  prod:
+    url: https://example.com/app/
+    append_query: v=1
+    headers:
+      Authorization: Bearer secret
+`), 0644)
+
+	for args, expected := range map[string]string{
+		"./pmr -dry-run -config " + p + " -profile prod":                         "https://example.com/app/index.php?v=1\n",
+		"./pmr -dry-run -config " + p + " -profile prod -u https://example.org/": "https://example.org/index.php?v=1\n",
+	} {
+		outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+		cli := &CLI{inStream: strings.NewReader("./index.php\n"), outStream: outStream, errStream: errStream}
+		if status := cli.Run(strings.Split(args, " ")); status != ExitCodeOK {
+			t.Errorf("expected %d to eq %d", status, ExitCodeOK)
+		}
+		if outStream.String() != expected {
+			t.Errorf("expected %q to eq %q", outStream.String(), expected)
+		}
+	}
+
+	c, err := loadConfig(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es := []*entry{{Path: "./a"}, {Path: "./b", Headers: map[string]string{"Authorization": "Basic x"}}}
+	c.Profiles["prod"].setHeaders(es)
+	if es[0].Headers["Authorization"] != "Bearer secret" || es[1].Headers["Authorization"] != "Basic x" {
+		t.Errorf("unexpected headers %v %v", es[0].Headers, es[1].Headers)
+	}
+}
+
+func TestRun_versionCommand(t *testing.T) {
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{outStream: outStream, errStream: errStream}
+	args := strings.Split("./pmr version", " ")
+
+	status := cli.Run(args)
+	if status != ExitCodeOK {
+		t.Errorf("expected %d to eq %d", status, ExitCodeOK)
+	}
+
+	expected := fmt.Sprintf("pmr version %s", Version)
+	if !strings.Contains(errStream.String(), expected) {
+		t.Errorf("expected %q to eq %q", errStream.String(), expected)
+	}
+}
+
+func TestRun_baselineAndReportCommand(t *testing.T) {
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results.json")
+	baseline := filepath.Join(dir, "baseline.json")
+	findings := []*finding{
+		{Kind: findingPublished, Path: "./a.php", URL: "https://example.com/a.php"},
+		{Kind: findingPublished, Path: "./a.php", URL: "https://example.com/a.php"},
+	}
+	if err := writeFindingsFile(results, findings); err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "baseline", "-out", baseline, results}); status != ExitCodeOK {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeOK, errStream.String())
+	}
+	known, err := readBaseline(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(known) != 1 || !known[findings[0].key()] {
+		t.Errorf("unexpected baseline %v", known)
+	}
+
+	outStream.Reset()
+	if status := cli.Run([]string{"./pmr", "report", results}); status != ExitCodeOK {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeOK, errStream.String())
+	}
+	if !strings.Contains(outStream.String(), "https://example.com/a.php") || !strings.Contains(outStream.String(), "2 findings") {
+		t.Errorf("unexpected report %q", outStream.String())
+	}
+}
+
+func TestRun_harFlag(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	dir := t.TempDir()
+	har := filepath.Join(dir, "out.har")
+	if err := ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./.env\n"), outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "-url", ts.URL, "-root", dir, "-har", har}); status != ExitCodeOK {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeOK, errStream.String())
+	}
+	b, err := ioutil.ReadFile(har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), ts.URL+"/.env") {
+		t.Errorf("expected the request to be recorded, got %q", b)
+	}
+}
+
+func TestRun_execFlag(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "SECRET=1\n")
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "exec.out")
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./.env\n"), outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "-url", ts.URL, "-root", dir, "-exec", "echo {kind} {path} >> " + out}); status != ExitCodeOK {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeOK, errStream.String())
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := findingPublished + " ./.env\n"
+	if string(b) != expected {
+		t.Errorf("expected %q to eq %q", b, expected)
+	}
+}
+
+func TestRun_deadlineFlag(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cpu := filepath.Join(dir, "cpu.pprof")
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./.env\n"), outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "-url", ts.URL, "-root", dir, "-deadline", "100ms", "-cpuprofile", cpu}); status != ExitCodeError {
+		t.Fatalf("expected %d to eq %d: %s", status, ExitCodeError, errStream.String())
+	}
+	if fi, err := os.Stat(cpu); err != nil || fi.Size() == 0 {
+		t.Errorf("expected the cpu profile to be written, got %v", err)
+	}
+}
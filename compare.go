@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// served is what a target of -compare-targets answered for a path.
+type served struct {
+	url    string
+	status int
+	sum    []byte
+}
+
+// compareTarget is a path of entries requested from one of the targets.
+type compareTarget struct {
+	i, j int
+	e    *entry
+}
+
+// compareTargets requests every path of entries from each of targets and
+// reports the paths that are served by some of them but not the others,
+// or served with different content. Each target is compared with the
+// first one. Like run, the requests go to a pool of workers that grows to
+// the limit of the gate.
+func (s *scanner) compareTargets(ctx context.Context, entries []*entry, targets []string) error {
+	answers := make([][]*served, len(entries))
+	ch := make(chan compareTarget)
+	eg := errgroup.Group{}
+	workers := 0
+feed:
+	for i, e := range entries {
+		answers[i] = make([]*served, len(targets))
+		for j, t := range targets {
+			want, _, _ := s.gate.state()
+			for ; workers < want; workers++ {
+				eg.Go(func() error {
+					var err error
+					for ct := range ch {
+						if !s.gate.acquire(ctx) {
+							continue
+						}
+						a, serr := s.serve(ctx, ct.e)
+						s.gate.release()
+						answers[ct.i][ct.j] = a
+						if serr != nil && err == nil {
+							err = serr
+						}
+					}
+					return err
+				})
+			}
+
+			select {
+			case ch <- compareTarget{i: i, j: j, e: &entry{Path: e.Path, URL: t, Headers: e.Headers}}:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(ch)
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	for i, e := range entries {
+		s.diverge(e, answers[i])
+	}
+	return nil
+}
+
+// serve requests e and hashes the whole body. It returns nil when the
+// error was skipped.
+func (s *scanner) serve(ctx context.Context, e *entry) (*served, error) {
+	m := newHashMatcher()
+	u, r, err := s.probe(withMatcher(ctx, m), e)
+	res := &Result{Path: e.Path, URL: u, Verdict: verdictNotPublished}
+	defer s.record(res)
+	switch {
+	case err != nil:
+		res.fail(err)
+		return nil, err
+	case r == nil:
+		res.Verdict = verdictSkipped
+		return nil, nil
+	}
+	res.Status = r.StatusCode
+	if r.StatusCode == http.StatusOK {
+		res.Verdict = verdictPublished
+	}
+	return &served{url: u, status: r.StatusCode, sum: m.sum()}, nil
+}
+
+// diverge reports the answers that differ from the first one.
+func (s *scanner) diverge(e *entry, answers []*served) {
+	if len(answers) == 0 {
+		return
+	}
+	ref := answers[0]
+	if ref == nil {
+		return
+	}
+	for _, a := range answers[1:] {
+		if a == nil {
+			continue
+		}
+		var msg string
+		switch {
+		case ref.status == http.StatusOK && a.status != http.StatusOK:
+			msg = fmt.Sprintf("%s is served at %s but %d at %s", e.Path, ref.url, a.status, a.url)
+		case ref.status != http.StatusOK && a.status == http.StatusOK:
+			msg = fmt.Sprintf("%s is served at %s but %d at %s", e.Path, a.url, ref.status, ref.url)
+		case a.status == http.StatusOK && !bytes.Equal(ref.sum, a.sum):
+			msg = fmt.Sprintf("%s differs between %s and %s", e.Path, ref.url, a.url)
+		default:
+			continue
+		}
+		f := &finding{Kind: findingDivergent, Path: e.Path, URL: a.url, Time: time.Now()}
+		if s.report(f) {
+			findingLog(f).Warn(msg)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanner_compareTargets(t *testing.T) {
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.html", "/new.html":
+			fmt.Fprint(w, "v2")
+		case "/same.html":
+			fmt.Fprint(w, "same")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer staging.Close()
+	prod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.html", "/old.html":
+			fmt.Fprint(w, "v1")
+		case "/same.html":
+			fmt.Fprint(w, "same")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer prod.Close()
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	entries := []*entry{{Path: "./index.html"}, {Path: "./new.html"}, {Path: "./old.html"}, {Path: "./same.html"}, {Path: "./gone.html"}}
+	if err := s.compareTargets(ctx, entries, []string{staging.URL, prod.URL}); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, f := range s.findings {
+		if f.Kind != findingDivergent {
+			t.Errorf("unexpected finding %+v", f)
+		}
+		got = append(got, f.URL)
+	}
+	sort.Strings(got)
+	expect := fmt.Sprint([]string{prod.URL + "/index.html", prod.URL + "/new.html", prod.URL + "/old.html"})
+	if fmt.Sprint(got) != expect {
+		t.Errorf("expected %q to eq %q", fmt.Sprint(got), expect)
+	}
+	if s.stats.requests != 10 {
+		t.Errorf("expected %d requests, got %d", 10, s.stats.requests)
+	}
+}
+
+func TestScanner_compareTargetsConcurrency(t *testing.T) {
+	var inFlight, max int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			m := atomic.LoadInt64(&max)
+			if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	entries := []*entry{}
+	for i := 0; i < 50; i++ {
+		entries = append(entries, &entry{Path: fmt.Sprintf("./%d.html", i)})
+	}
+	if err := s.compareTargets(ctx, entries, []string{ts.URL, ts.URL + "/v2"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.stats.requests != 100 {
+		t.Errorf("expected %d requests, got %d", 100, s.stats.requests)
+	}
+	if max > 2 {
+		t.Errorf("expected at most %d requests at once, got %d", 2, max)
+	}
+}
+
+func TestRun_compareTargetsDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./a.html\n./b.html\n"), outStream: outStream, errStream: errStream}
+	args := []string{"./pmr", "-root", dir, "-compare-targets", ts.URL + "," + ts.URL + "/v2", "-c", "1", "-deadline", "100ms", "-skip-network-errors"}
+	if status := cli.Run(args); status != ExitCodeError {
+		t.Errorf("expected an unfinished comparison to fail, got %d: %s", status, errStream.String())
+	}
+}
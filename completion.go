@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// subcommand is a command of `pmr <command>` as offered by completion.
+type subcommand struct {
+	name  string
+	usage string
+	run   func(*CLI, []string) int
+}
+
+// subcommands returns the commands of Run. Scan is also the default
+// without a command, so its flags are completed after `pmr` too.
+func subcommands() []*subcommand {
+	return []*subcommand{
+		{"scan", "check whether the paths on stdin are published", (*CLI).runScan},
+		{"report", "print saved findings", (*CLI).runReport},
+		{"baseline", "merge saved findings into a baseline file", (*CLI).runBaseline},
+		{"serve", "start the HTTP API and scheduled scans", (*CLI).runServe},
+		{"replay", "request saved findings again to see which are still exposed", (*CLI).runReplay},
+		{"prune", "delete old scans from a results database", (*CLI).runPrune},
+		{"self-update", "update pmr to the latest release", (*CLI).runSelfUpdate},
+		{"completion", "print a shell completion script", nil},
+		{"version", "print the version", nil},
+	}
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// errFlagsOnly stops a command after its flags are defined, when they are
+// only collected for completion.
+var errFlagsOnly = errors.New("flags only")
+
+// parse parses the flags of a command, or only hands them to flagSets.
+func (cli *CLI) parse(flags *flag.FlagSet, args []string) error {
+	if cli.flagSets != nil {
+		cli.flagSets(flags)
+		return errFlagsOnly
+	}
+	return flags.Parse(args)
+}
+
+// commandFlags returns the flags every command defines, sorted by name.
+func commandFlags() map[string][]*flag.Flag {
+	flags := map[string][]*flag.Flag{}
+	for _, c := range subcommands() {
+		if c.run == nil {
+			continue
+		}
+		fs := []*flag.Flag{}
+		cli := &CLI{outStream: ioutil.Discard, errStream: ioutil.Discard, flagSets: func(f *flag.FlagSet) {
+			f.VisitAll(func(f *flag.Flag) { fs = append(fs, f) })
+		}}
+		c.run(cli, []string{c.name})
+		sort.Slice(fs, func(i, j int) bool { return fs[i].Name < fs[j].Name })
+		flags[c.name] = fs
+	}
+	return flags
+}
+
+// runCompletion prints the completion script of a shell.
+func (cli *CLI) runCompletion(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(cli.errStream, "Usage: %s completion %s\n", Name, strings.Join(completionShells, "|"))
+		return ExitCodeError
+	}
+	switch args[1] {
+	case "bash":
+		writeBashCompletion(cli.outStream, commandFlags())
+	case "zsh":
+		writeZshCompletion(cli.outStream, commandFlags())
+	case "fish":
+		writeFishCompletion(cli.outStream, commandFlags())
+	default:
+		fmt.Fprintf(cli.errStream, "unknown shell %s, expected one of %s\n", args[1], strings.Join(completionShells, ", "))
+		return ExitCodeError
+	}
+	return ExitCodeOK
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// isFileFlag guesses from its usage whether the value of f is a path.
+func isFileFlag(f *flag.Flag) bool {
+	u := strings.ToLower(f.Usage)
+	return strings.Contains(u, "file") || strings.Contains(u, "directory")
+}
+
+func flagNames(fs []*flag.Flag) string {
+	names := []string{}
+	for _, f := range fs {
+		names = append(names, "-"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(w io.Writer, flags map[string][]*flag.Flag) {
+	names := []string{}
+	for _, c := range subcommands() {
+		names = append(names, c.name)
+	}
+	fmt.Fprintf(w, "# bash completion for %s, generated by `%s completion bash`\n", Name, Name)
+	fmt.Fprintf(w, "_%s() {\n", Name)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" opts\n")
+	fmt.Fprintf(w, "\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " ")+" "+flagNames(flags["scan"]))
+	fmt.Fprintf(w, "\t\treturn\n\tfi\n")
+	fmt.Fprintf(w, "\tcase \"${COMP_WORDS[1]}\" in\n")
+	for _, c := range subcommands() {
+		switch {
+		case c.name == "completion":
+			fmt.Fprintf(w, "\t%s) opts=%q ;;\n", c.name, strings.Join(completionShells, " "))
+		case c.run != nil:
+			fmt.Fprintf(w, "\t%s) opts=%q ;;\n", c.name, flagNames(flags[c.name]))
+		}
+	}
+	fmt.Fprintf(w, "\t-*) opts=%q ;;\n", flagNames(flags["scan"]))
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $cur == -* || ${COMP_WORDS[1]} == completion ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"$opts\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\tfi\n}\n")
+	fmt.Fprintf(w, "complete -o default -F _%s %s\n", Name, Name)
+}
+
+// zshQuote escapes s for a single quoted _arguments spec.
+func zshQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+	return strings.Replace(s, "'", `'\''`, -1)
+}
+
+func writeZshCompletion(w io.Writer, flags map[string][]*flag.Flag) {
+	fmt.Fprintf(w, "#compdef %s\n# zsh completion for %s, generated by `%s completion zsh`\n\n", Name, Name, Name)
+	for _, c := range subcommands() {
+		if c.run == nil {
+			continue
+		}
+		fmt.Fprintf(w, "_%s_%s() {\n\t_arguments \\\n", Name, strings.Replace(c.name, "-", "_", -1))
+		for _, f := range flags[c.name] {
+			spec := "-" + f.Name + "[" + zshQuote(f.Usage) + "]"
+			switch {
+			case isBoolFlag(f):
+			case isFileFlag(f):
+				spec += ":" + f.Name + ":_files"
+			default:
+				spec += ":" + f.Name + ":"
+			}
+			fmt.Fprintf(w, "\t\t'%s' \\\n", spec)
+		}
+		fmt.Fprintf(w, "\t\t'*:file:_files'\n}\n\n")
+	}
+
+	fmt.Fprintf(w, "_%s() {\n\tlocal -a commands\n\tcommands=(\n", Name)
+	for _, c := range subcommands() {
+		fmt.Fprintf(w, "\t\t'%s:%s'\n", c.name, zshQuote(c.usage))
+	}
+	fmt.Fprintf(w, "\t)\n")
+	fmt.Fprintf(w, "\tif (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n\t\t_describe command commands\n\t\treturn\n\tfi\n")
+	fmt.Fprintf(w, "\tif [[ $words[2] == -* ]]; then\n\t\t_%s_scan\n\t\treturn\n\tfi\n", Name)
+	fmt.Fprintf(w, "\tlocal cmd=$words[2]\n\tshift words\n\t(( CURRENT-- ))\n")
+	fmt.Fprintf(w, "\tcase $cmd in\n")
+	for _, c := range subcommands() {
+		switch {
+		case c.name == "completion":
+			fmt.Fprintf(w, "\t%s) _values shell %s ;;\n", c.name, strings.Join(completionShells, " "))
+		case c.run != nil:
+			fmt.Fprintf(w, "\t%s) _%s_%s ;;\n", c.name, Name, strings.Replace(c.name, "-", "_", -1))
+		}
+	}
+	fmt.Fprintf(w, "\tesac\n}\n\n_%s \"$@\"\n", Name)
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, flags map[string][]*flag.Flag) {
+	names := []string{}
+	for _, c := range subcommands() {
+		names = append(names, c.name)
+	}
+	fmt.Fprintf(w, "# fish completion for %s, generated by `%s completion fish`\n", Name, Name)
+	for _, c := range subcommands() {
+		fmt.Fprintf(w, "complete -c %s -f -n '__fish_use_subcommand' -a %s -d %s\n", Name, c.name, fishQuote(c.usage))
+	}
+	fmt.Fprintf(w, "complete -c %s -f -n '__fish_seen_subcommand_from completion' -a %s\n", Name, fishQuote(strings.Join(completionShells, " ")))
+	for _, c := range subcommands() {
+		if c.run == nil {
+			continue
+		}
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == "scan" {
+			cond = "not __fish_seen_subcommand_from " + strings.Join(names[1:], " ")
+		}
+		for _, f := range flags[c.name] {
+			opt := ""
+			switch {
+			case isBoolFlag(f):
+			case isFileFlag(f):
+				opt = " -r -F"
+			default:
+				opt = " -r -f"
+			}
+			fmt.Fprintf(w, "complete -c %s -n %s -o %s%s -d %s\n", Name, fishQuote(cond), f.Name, opt, fishQuote(f.Usage))
+		}
+	}
+}
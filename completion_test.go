@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	flags := commandFlags()
+	for cmd, name := range map[string]string{"scan": "url", "report": "github-pr", "baseline": "out", "serve": "listen", "self-update": "check"} {
+		found := false
+		for _, f := range flags[cmd] {
+			found = found || f.Name == name
+		}
+		if !found {
+			t.Errorf("expected %s to have -%s", cmd, name)
+		}
+	}
+}
+
+func TestCLI_completion(t *testing.T) {
+	for _, c := range []struct {
+		shell  string
+		expect []string
+	}{
+		{"bash", []string{"complete -o default -F _pmr pmr", "report) opts=\"-db -github-api -github-pr -since -token -url\" ;;"}},
+		{"zsh", []string{"#compdef pmr", "'-baseline[do not report findings recorded in this baseline file]:baseline:_files' \\", "'-crawl[crawl the url and report served files that are not in the input paths]' \\"}},
+		{"fish", []string{"complete -c pmr -n '__fish_seen_subcommand_from baseline' -o out -r -F -d 'baseline file to create or update'"}},
+	} {
+		out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+		cli := &CLI{outStream: out, errStream: errs}
+		if status := cli.Run([]string{"pmr", "completion", c.shell}); status != ExitCodeOK {
+			t.Fatalf("expected %d to eq %d: %s", status, ExitCodeOK, errs)
+		}
+		for _, e := range c.expect {
+			if !strings.Contains(out.String(), e) {
+				t.Errorf("expected %s completion to contain %q", c.shell, e)
+			}
+		}
+	}
+
+	cli := &CLI{outStream: &bytes.Buffer{}, errStream: &bytes.Buffer{}}
+	if status := cli.Run([]string{"pmr", "completion", "tcsh"}); status != ExitCodeError {
+		t.Errorf("expected %d to eq %d", status, ExitCodeError)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// config is the file given to `serve -config` and `scan -config`.
+type config struct {
+	SMTP      *smtpConfig               `yaml:"smtp"`
+	Schedules []*scheduleConfig         `yaml:"schedules"`
+	Profiles  map[string]*profileConfig `yaml:"profiles"`
+}
+
+// profileConfig is a named set of scan settings selected with
+// `scan -profile`, so that one config file can drive the scans of many
+// applications. Flags given on the command line take precedence.
+type profileConfig struct {
+	URL         string            `yaml:"url"`
+	Headers     map[string]string `yaml:"headers"`
+	Rules       string            `yaml:"rules"`
+	PublicList  string            `yaml:"public_list"`
+	Baseline    string            `yaml:"baseline"`
+	Root        string            `yaml:"root"`
+	AppendQuery string            `yaml:"append_query"`
+	StripQuery  bool              `yaml:"strip_query"`
+	Concurrency int               `yaml:"concurrency"`
+}
+
+// apply sets the scan flags of p that weren't given on the command line,
+// under any of their names.
+func (p *profileConfig) apply(flags *flag.FlagSet) error {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	values := map[string]string{
+		"url":          p.URL,
+		"rules":        p.Rules,
+		"public-list":  p.PublicList,
+		"baseline":     p.Baseline,
+		"root":         p.Root,
+		"append-query": p.AppendQuery,
+	}
+	if p.StripQuery {
+		values["strip-query"] = "true"
+	}
+	if p.Concurrency > 0 {
+		values["concurrency"] = strconv.Itoa(p.Concurrency)
+	}
+	aliases := map[string]string{"url": "u", "concurrency": "c"}
+	for name, v := range values {
+		if v == "" || set[name] || set[aliases[name]] {
+			continue
+		}
+		if err := flags.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setHeaders adds the headers of p to entries that don't set them.
+func (p *profileConfig) setHeaders(entries []*entry) {
+	if len(p.Headers) == 0 {
+		return
+	}
+	for _, e := range entries {
+		if e.Headers == nil {
+			e.Headers = map[string]string{}
+		}
+		for k, v := range p.Headers {
+			if _, ok := e.Headers[k]; !ok {
+				e.Headers[k] = v
+			}
+		}
+	}
+}
+
+// scheduleConfig is a scan run by the server on a cron schedule. Paths
+// are read from a file in the plain or NDJSON input format, or taken from
+// a sitemap or robots.txt, every time the job runs.
+type scheduleConfig struct {
+	Name        string       `yaml:"name"`
+	Cron        string       `yaml:"cron"`
+	URL         string       `yaml:"url"`
+	Paths       string       `yaml:"paths"`
+	InputFormat string       `yaml:"input_format"`
+	Sitemap     string       `yaml:"sitemap"`
+	Robots      string       `yaml:"robots"`
+	Concurrency int          `yaml:"concurrency"`
+	Notify      notifyConfig `yaml:"notify"`
+
+	cron *cronSchedule
+}
+
+// notifyConfig is where the result of a scheduled scan is sent.
+type notifyConfig struct {
+	Webhook string   `yaml:"webhook"`
+	Email   []string `yaml:"email"`
+}
+
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if c.SMTP != nil {
+		if c.SMTP.Host == "" || c.SMTP.From == "" {
+			return nil, fmt.Errorf("%s: smtp needs host and from", path)
+		}
+		if c.SMTP.Port == 0 {
+			c.SMTP.Port = 25
+		}
+	}
+
+	names := map[string]bool{}
+	for i, sc := range c.Schedules {
+		if sc.Name == "" {
+			sc.Name = fmt.Sprintf("schedule-%d", i+1)
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("%s: duplicate schedule %q", path, sc.Name)
+		}
+		names[sc.Name] = true
+
+		if sc.cron, err = parseCron(sc.Cron); err != nil {
+			return nil, fmt.Errorf("%s: schedule %q: %s", path, sc.Name, err)
+		}
+		if sc.URL == "" {
+			return nil, fmt.Errorf("%s: schedule %q: url is required", path, sc.Name)
+		}
+		if sc.Paths == "" && sc.Sitemap == "" && sc.Robots == "" {
+			return nil, fmt.Errorf("%s: schedule %q: one of paths, sitemap or robots is required", path, sc.Name)
+		}
+		if len(sc.Notify.Email) > 0 && c.SMTP == nil {
+			return nil, fmt.Errorf("%s: schedule %q: email needs smtp settings", path, sc.Name)
+		}
+		if sc.InputFormat == "" {
+			sc.InputFormat = inputFormatPlain
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// scriptExtensions are run by the server, so their source should never be
+// served as is.
+var scriptExtensions = map[string]bool{
+	".php":   true,
+	".php5":  true,
+	".phtml": true,
+	".asp":   true,
+	".aspx":  true,
+	".jsp":   true,
+	".cfm":   true,
+	".cgi":   true,
+	".pl":    true,
+	".py":    true,
+	".rb":    true,
+}
+
+// sourceTypes are content types servers use for scripts they don't run.
+var sourceTypes = map[string]bool{
+	"text/plain":                     true,
+	"application/octet-stream":       true,
+	"application/x-httpd-php":        true,
+	"application/x-httpd-php-source": true,
+	"application/x-php":              true,
+	"application/x-perl":             true,
+	"application/x-python":           true,
+	"application/x-ruby":             true,
+	"application/x-sh":               true,
+}
+
+// typeAliases maps content types to the one mime.TypeByExtension returns.
+var typeAliases = map[string]string{
+	"application/javascript":   "text/javascript",
+	"application/x-javascript": "text/javascript",
+	"application/xml":          "text/xml",
+}
+
+// checkContentType compares the content type of a response with the
+// extension of p. source reports a script served as source instead of
+// being run, and expected is set when any other known type differs.
+func checkContentType(p string, h http.Header) (source bool, got, expected string) {
+	ext := strings.ToLower(path.Ext(p))
+	got = mediaType(h.Get("Content-Type"))
+	if scriptExtensions[ext] {
+		return sourceTypes[got] || strings.HasPrefix(got, "text/x-"), got, ""
+	}
+	expected = mediaType(mime.TypeByExtension(ext))
+	if expected == "" || got == "" || got == expected {
+		return false, got, ""
+	}
+	return false, got, expected
+}
+
+func mediaType(v string) string {
+	t, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return ""
+	}
+	if a, ok := typeAliases[t]; ok {
+		return a
+	}
+	return t
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckContentType(t *testing.T) {
+	tests := []struct {
+		path        string
+		contentType string
+		source      bool
+		expected    string
+	}{
+		{"./index.php", "text/html; charset=UTF-8", false, ""},
+		{"./index.php", "application/x-httpd-php", true, ""},
+		{"./index.php", "text/x-php", true, ""},
+		{"./app.cgi", "text/plain", true, ""},
+		{"./app.js", "application/javascript", false, ""},
+		{"./style.css", "text/css", false, ""},
+		{"./style.css", "text/html", false, "text/css"},
+		{"./data.unknown", "text/html", false, ""},
+		{"./logo.png", "", false, ""},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.contentType != "" {
+			h.Set("Content-Type", tt.contentType)
+		}
+		source, _, expected := checkContentType(tt.path, h)
+		if source != tt.source || expected != tt.expected {
+			t.Errorf("expected %q %q to eq %v %q, got %v %q", tt.path, tt.contentType, tt.source, tt.expected, source, expected)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const workerPollInterval = time.Second
+
+// coordinator shards a scan across `pmr serve` workers. The heads of the
+// local files are read by the coordinator and sent with the entries, so
+// workers don't need a copy of the tree.
+type coordinator struct {
+	client      *http.Client
+	workers     []string
+	concurrency int
+	poll        time.Duration
+	// token is sent to the workers as a bearer token when set.
+	token string
+}
+
+func newCoordinator(client *http.Client, workers []string, concurrency int) *coordinator {
+	return &coordinator{
+		client:      client,
+		workers:     workers,
+		concurrency: concurrency,
+		poll:        workerPollInterval,
+	}
+}
+
+// scan runs entries on the workers and reports their findings through s.
+// Scans already started keep running on the workers when ctx is done.
+func (c *coordinator) scan(ctx context.Context, s *scanner, entries []*entry) error {
+	shards := make([][]*entry, len(c.workers))
+	n := 0
+	for _, e := range entries {
+		skip, err := s.skipLocal(e)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		if e.Head == nil {
+			head, err := getFileHead(s.localPath(e))
+			if err != nil {
+				if os.IsNotExist(err) && e.source != "" {
+					logrus.Infof("%s is listed in %s but not in the local tree", e.Path, e.source)
+					continue
+				}
+				if s.skipError(errorLocal, err) {
+					continue
+				}
+				return err
+			}
+			e.Head = head
+		}
+		shards[n%len(shards)] = append(shards[n%len(shards)], e)
+		n++
+	}
+
+	eg := errgroup.Group{}
+	for i, w := range c.workers {
+		w, shard := strings.TrimRight(w, "/"), shards[i]
+		if len(shard) == 0 {
+			continue
+		}
+		eg.Go(func() error {
+			job, err := c.run(ctx, w, shard)
+			if err != nil {
+				return fmt.Errorf("worker %s: %s", w, err)
+			}
+			atomic.AddInt64(&s.stats.requests, job.Requests)
+			atomic.AddInt64(&s.stats.errors, job.Errors)
+			for _, f := range job.Findings {
+				// workers classify by their own rules, so the findings
+				// are classified again by the -rules of the coordinator.
+				// Header findings carry the severity of the rule that
+				// matched the response instead.
+				if f.Kind != findingHeader {
+					f.Severity, f.Message = "", ""
+				}
+				if s.report(f) {
+					_, msg := f.describe()
+					findingLog(f).Warnf("%s: %s", f.Path, msg)
+				}
+			}
+			if job.Status == jobFailed {
+				return fmt.Errorf("worker %s: %s", w, job.Error)
+			}
+			logrus.Infof("worker %s finished %d paths", w, job.Total)
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// run starts a scan of entries on worker and waits for it to finish.
+func (c *coordinator) run(ctx context.Context, worker string, entries []*entry) (*scanJob, error) {
+	b, err := json.Marshal(&scanRequest{Entries: entries, Concurrency: c.concurrency})
+	if err != nil {
+		return nil, err
+	}
+	job := &scanJob{}
+	if err := c.do(ctx, http.MethodPost, worker+"/scans", b, http.StatusAccepted, job); err != nil {
+		return nil, err
+	}
+	logrus.Infof("worker %s started scan %s of %d paths", worker, job.ID, len(entries))
+
+	for job.Status == jobRunning {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.poll):
+		}
+		if err := c.do(ctx, http.MethodGet, worker+"/scans/"+job.ID, nil, http.StatusOK, job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+func (c *coordinator) do(ctx context.Context, method, u string, body []byte, status int, v interface{}) error {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		e := map[string]string{}
+		json.NewDecoder(res.Body).Decode(&e)
+		return fmt.Errorf("%s %s: %s %s", method, u, res.Status, e["error"])
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
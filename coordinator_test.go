@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoordinator_scan(t *testing.T) {
+	dir := t.TempDir()
+	entries := []*entry{}
+	for _, name := range []string{"a.php", "b.php", "c.php"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("<?php secret"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, &entry{Path: p})
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+	for _, e := range entries {
+		e.URL = target.URL
+	}
+
+	workers := []string{}
+	servers := []*server{}
+	for i := 0; i < 2; i++ {
+		sv := newServer(newFetchers(target.Client(), 3, &tls.Config{}, nil), 2)
+		sv.token = "secret"
+		w := httptest.NewServer(sv.handler())
+		defer w.Close()
+		workers = append(workers, w.URL)
+		servers = append(servers, sv)
+	}
+
+	c := newCoordinator(http.DefaultClient, workers, 2)
+	c.poll = 10 * time.Millisecond
+	c.token = "secret"
+	s := &scanner{rules: severityRules{{pattern: "**/a.php", severity: severityCritical, message: "entry point"}}}
+	if err := c.scan(context.Background(), s, entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 3 || s.stats.requests != 3 {
+		t.Errorf("unexpected findings %+v of %d requests", s.findings, s.stats.requests)
+	}
+	for _, f := range s.findings {
+		expected := severityDefault
+		if filepath.Base(f.Path) == "a.php" {
+			expected = severityCritical
+		}
+		if f.Severity != expected {
+			t.Errorf("%s: expected %q to eq %q", f.Path, f.Severity, expected)
+		}
+	}
+	for i, sv := range servers {
+		if len(sv.jobs) != 1 {
+			t.Errorf("expected worker %d to run 1 scan, got %d", i, len(sv.jobs))
+		}
+	}
+}
+
+func TestRun_workersDeadline(t *testing.T) {
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		fmt.Fprint(w, `{"id":"1","status":"running"}`)
+	}))
+	defer worker.Close()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./a.php\n"), outStream: outStream, errStream: errStream}
+	args := []string{"./pmr", "-url", "https://example.com/", "-root", dir, "-workers", worker.URL, "-deadline", "100ms"}
+	if status := cli.Run(args); status != ExitCodeError {
+		t.Errorf("expected an unfinished sharded scan to fail, got %d: %s", status, errStream.String())
+	}
+}
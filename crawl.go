@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxCrawlBodySize caps how much of a page is read for link extraction.
+const maxCrawlBodySize = 1024 * 1024 * 2
+
+var linkPattern = regexp.MustCompile(`(?i)(?:href|src)\s*=\s*["']([^"'#]+)`)
+
+// crawler walks the target site breadth first up to maxDepth, collecting
+// every URL that was served successfully.
+type crawler struct {
+	client      *http.Client
+	base        *url.URL
+	maxDepth    int
+	concurrency int
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	served map[string]bool
+}
+
+func newCrawler(client *http.Client, baseURL string, maxDepth, concurrency int) (*crawler, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &crawler{
+		client:      client,
+		base:        u,
+		maxDepth:    maxDepth,
+		concurrency: concurrency,
+		seen:        map[string]bool{},
+		served:      map[string]bool{},
+	}, nil
+}
+
+// crawl returns the paths of all served URLs in sorted order.
+func (cr *crawler) crawl(ctx context.Context) ([]string, error) {
+	level := []string{cr.base.String()}
+	cr.seen[cr.base.String()] = true
+	for depth := 0; depth <= cr.maxDepth && len(level) > 0; depth++ {
+		var next []string
+		c := make(chan bool, cr.concurrency)
+		eg := errgroup.Group{}
+		for _, u := range level {
+			u := u
+			c <- true
+			eg.Go(func() error {
+				defer func() { <-c }()
+				links, err := cr.visit(ctx, u)
+				if err != nil {
+					logrus.Error(err)
+					return nil
+				}
+				if depth == cr.maxDepth {
+					return nil
+				}
+				cr.mu.Lock()
+				defer cr.mu.Unlock()
+				for _, l := range links {
+					if !cr.seen[l] {
+						cr.seen[l] = true
+						next = append(next, l)
+					}
+				}
+				return nil
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+		level = next
+	}
+
+	paths := make([]string, 0, len(cr.served))
+	for p := range cr.served {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// visit fetches u and returns the same-host links found on it.
+func (cr *crawler) visit(ctx context.Context, u string) ([]string, error) {
+	req, err := newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	r, err := cr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, r.Body)
+		return nil, nil
+	}
+	cr.mu.Lock()
+	cr.served[r.Request.URL.Path] = true
+	cr.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/html") {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCrawlBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	links := []string{}
+	for _, m := range linkPattern.FindAllStringSubmatch(string(body), -1) {
+		ref, err := url.Parse(strings.TrimSpace(m[1]))
+		if err != nil {
+			continue
+		}
+		l := r.Request.URL.ResolveReference(ref)
+		if l.Host != cr.base.Host || (l.Scheme != "http" && l.Scheme != "https") {
+			continue
+		}
+		l.Fragment = ""
+		links = append(links, l.String())
+	}
+	return links, nil
+}
+
+// unexpectedPaths returns the served paths that no entry resolves to.
+// Directory URLs are index pages and are never reported.
+func unexpectedPaths(served []string, entries []*entry) ([]string, error) {
+	expected := map[string]bool{}
+	for _, e := range entries {
+		u, err := urlJoin(e.URL, e.Path)
+		if err != nil {
+			return nil, err
+		}
+		pu, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		expected[pu.Path] = true
+	}
+
+	unexpected := []string{}
+	for _, p := range served {
+		if p == "" || strings.HasSuffix(p, "/") || expected[p] {
+			continue
+		}
+		unexpected = append(unexpected, p)
+	}
+	return unexpected, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCrawl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/a.php">a</a><a href='docs/'>docs</a><a href="https://example.com/x">x</a>`)
+		case "/docs/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<img src="../backup.sql"><a href="../missing.php#top">m</a>`)
+		case "/a.php", "/backup.sql":
+			fmt.Fprint(w, "ok")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cr, err := newCrawler(ts.Client(), ts.URL+"/", 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served, err := cr.crawl(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/", "/a.php", "/backup.sql", "/docs/"}
+	if !reflect.DeepEqual(served, expected) {
+		t.Errorf("expected %q to eq %q", served, expected)
+	}
+
+	unexpected, err := unexpectedPaths(served, []*entry{{Path: "./a.php", URL: ts.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"/backup.sql"}
+	if !reflect.DeepEqual(unexpected, expected) {
+		t.Errorf("expected %q to eq %q", unexpected, expected)
+	}
+}
+
+func TestRun_crawlDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{inStream: strings.NewReader("./a.php\n"), outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "-url", ts.URL + "/", "-root", dir, "-crawl", "-deadline", "100ms"}); status != ExitCodeError {
+		t.Errorf("expected %d to eq %d: %s", status, ExitCodeError, errStream.String())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// credential is what a credential helper answered for a host. A token is
+// sent as a bearer token, a login and password as basic auth.
+type credential struct {
+	login    string
+	password string
+	token    string
+}
+
+// credentialHelper asks an external command for the credentials of every
+// host, so that they never show up in flags, config files or the process
+// list. Like with netrc, the command gets `machine <host>` and `protocol
+// <scheme>` lines on stdin and prints `login`, `password` and `token`
+// pairs on stdout, or nothing when it has no credentials for the host.
+// It runs once per host, or again after it failed.
+type credentialHelper struct {
+	command string
+
+	mu    sync.Mutex
+	hosts map[string]*credentialEntry
+}
+
+// credentialEntry is a run of the helper, which is done once ready is
+// closed.
+type credentialEntry struct {
+	ready chan struct{}
+	cred  *credential
+	err   error
+}
+
+// redacted replaces credentials in the request headers kept as evidence.
+const redacted = "[redacted]"
+
+func newCredentialHelper(command string) *credentialHelper {
+	return &credentialHelper{command: command, hosts: map[string]*credentialEntry{}}
+}
+
+// get returns the credentials for the host of u, or nil when the helper
+// has none.
+func (h *credentialHelper) get(ctx context.Context, u *url.URL) (*credential, error) {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	h.mu.Lock()
+	e, ok := h.hosts[key]
+	if !ok {
+		e = &credentialEntry{ready: make(chan struct{})}
+		h.hosts[key] = e
+		// the run is shared, so it isn't canceled along with the context
+		// of the caller that happens to start it
+		go func() {
+			cred, err := h.run(context.Background(), u)
+			if err != nil {
+				// failures are not kept, the next request runs it again
+				h.mu.Lock()
+				delete(h.hosts, key)
+				h.mu.Unlock()
+			}
+			e.cred, e.err = cred, err
+			close(e.ready)
+		}()
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.cred, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// authorize sets the Authorization header of req from c.
+func (c *credential) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		return
+	}
+	req.SetBasicAuth(c.login, c.password)
+}
+
+func (h *credentialHelper) run(ctx context.Context, u *url.URL) (*credential, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("machine %s\nprotocol %s\n", u.Hostname(), u.Scheme))
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper for %s: %s: %s", u.Host, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	cred, err := parseCredential(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("credential helper for %s: %s", u.Host, err)
+	}
+	return cred, nil
+}
+
+// parseCredential parses the netrc style keyword and value pairs printed
+// by a credential helper. The values aren't part of errors, they may be
+// secrets.
+func parseCredential(s string) (*credential, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("expected keyword and value pairs")
+	}
+	c := &credential{}
+	for i := 0; i < len(fields); i += 2 {
+		switch fields[i] {
+		case "login":
+			c.login = fields[i+1]
+		case "password":
+			c.password = fields[i+1]
+		case "token":
+			c.token = fields[i+1]
+		case "machine", "protocol", "account":
+		default:
+			return nil, fmt.Errorf("unknown keyword %q", fields[i])
+		}
+	}
+	if c.login == "" && c.password == "" && c.token == "" {
+		return nil, nil
+	}
+	return c, nil
+}
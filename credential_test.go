@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCredential(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected *credential
+		err      bool
+	}{
+		{"login alice password s3cret\n", &credential{login: "alice", password: "s3cret"}, false},
+		{"machine example.com\ntoken abc\n", &credential{token: "abc"}, false},
+		{"", nil, false},
+		{"login", nil, true},
+		{"user alice", nil, true},
+	}
+	for _, tt := range tests {
+		c, err := parseCredential(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.in, err)
+		}
+		if !reflect.DeepEqual(c, tt.expected) {
+			t.Errorf("expected %+v to eq %+v", c, tt.expected)
+		}
+	}
+}
+
+func TestHTTPFetcher_credentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "alice" || p != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	// the helper counts its runs, it should run once per host
+	count := filepath.Join(t.TempDir(), "count")
+	h := newCredentialHelper(`grep -q "^machine 127.0.0.1$" && echo x >> ` + count + ` && echo "login alice password s3cret"`)
+	f := &httpFetcher{client: ts.Client(), credentials: h}
+	for i := 0; i < 2; i++ {
+		r, err := f.Fetch(context.Background(), &entry{Path: "./index.html"}, ts.URL+"/index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != http.StatusOK {
+			t.Errorf("expected %d to eq %d", r.StatusCode, http.StatusOK)
+		}
+		if got := r.RequestHeader.Get("Authorization"); got != redacted {
+			t.Errorf("expected %q to eq %q", got, redacted)
+		}
+	}
+	b, err := ioutil.ReadFile(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "x\n" {
+		t.Errorf("expected the helper to run once, got %q", b)
+	}
+
+	h = newCredentialHelper("echo oops >&2; exit 1")
+	f = &httpFetcher{client: ts.Client(), credentials: h}
+	if _, err := f.Fetch(context.Background(), &entry{Path: "./index.html"}, ts.URL+"/index.html"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected the helper error, got %v", err)
+	}
+}
+
+func TestCredentialHelper_retry(t *testing.T) {
+	u, _ := url.Parse("https://example.com/")
+
+	// the helper fails on its first run only
+	count := filepath.Join(t.TempDir(), "count")
+	h := newCredentialHelper(`echo x >> ` + count + `; test "$(wc -l < ` + count + `)" -gt 1 && echo "token abc"`)
+	if _, err := h.get(context.Background(), u); err == nil {
+		t.Error("expected the first run to fail")
+	}
+	if c, err := h.get(context.Background(), u); err != nil || c == nil || c.token != "abc" {
+		t.Errorf("expected the helper to run again, got %+v %v", c, err)
+	}
+
+	// a canceled caller doesn't cancel the run for the others
+	h = newCredentialHelper(`sleep 0.1; echo "token abc"`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.get(ctx, u); err != context.Canceled {
+		t.Errorf("expected %v to eq %v", err, context.Canceled)
+	}
+	if c, err := h.get(context.Background(), u); err != nil || c == nil || c.token != "abc" {
+		t.Errorf("expected the credentials, got %+v %v", c, err)
+	}
+}
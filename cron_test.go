@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec string
+		time string
+		want bool
+	}{
+		{"* * * * *", "2024-03-05 12:34", true},
+		{"0 3 * * *", "2024-03-05 03:00", true},
+		{"0 3 * * *", "2024-03-05 03:01", false},
+		{"*/15 * * * *", "2024-03-05 10:45", true},
+		{"*/15 * * * *", "2024-03-05 10:50", false},
+		{"0 9-17 * * 1-5", "2024-03-05 12:00", true},
+		{"0 9-17 * * 1-5", "2024-03-09 12:00", false},
+		{"0 0 * * 7", "2024-03-10 00:00", true},
+		{"0 0 1,15 * *", "2024-03-15 00:00", true},
+		// either restricted day field may match
+		{"0 0 1 * 2", "2024-03-05 00:00", true},
+		{"@daily", "2024-03-05 00:00", true},
+		{"@daily", "2024-03-05 01:00", false},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.spec, err)
+		}
+		if got := c.match(at(tt.time)); got != tt.want {
+			t.Errorf("expected %q at %s to eq %v", tt.spec, tt.time, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * * mon", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("expected %q to be an error", spec)
+		}
+	}
+}
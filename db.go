@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const dbSchema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	target      TEXT NOT NULL,
+	started_at  INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL,
+	requests    INTEGER NOT NULL,
+	errors      INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS findings (
+	scan_id  INTEGER NOT NULL REFERENCES scans(id),
+	kind     TEXT NOT NULL,
+	path     TEXT NOT NULL,
+	url      TEXT NOT NULL,
+	time     INTEGER NOT NULL,
+	evidence TEXT NOT NULL DEFAULT '',
+	severity TEXT NOT NULL DEFAULT '',
+	message  TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS findings_time ON findings(time);
+`
+
+// resultDB keeps the findings of every scan in SQLite, so they can be
+// compared across runs. Times are stored as unix nanoseconds.
+type resultDB struct {
+	db *sql.DB
+}
+
+func openResultDB(path string) (*resultDB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(dbSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &resultDB{db: db}, nil
+}
+
+func (r *resultDB) Close() error {
+	return r.db.Close()
+}
+
+// saveScan records a scan of target with its stats and findings.
+func (r *resultDB) saveScan(target string, started, finished time.Time, st *stats, findings []*finding) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO scans (target, started_at, finished_at, requests, errors) VALUES (?, ?, ?, ?, ?)`,
+		target, started.UnixNano(), finished.UnixNano(), atomic.LoadInt64(&st.requests), atomic.LoadInt64(&st.errors))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		if _, err := tx.Exec(`INSERT INTO findings (scan_id, kind, path, url, time, evidence, severity, message) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, f.Kind, f.Path, f.URL, f.Time.UnixNano(), f.Evidence, f.Severity, f.Message); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// prune deletes the scans finished before and their findings, and
+// compacts the database file.
+func (r *resultDB) prune(before time.Time) (scans, findings int64, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM findings WHERE scan_id IN (SELECT id FROM scans WHERE finished_at < ?)`, before.UnixNano())
+	if err != nil {
+		return 0, 0, err
+	}
+	if findings, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	if res, err = tx.Exec(`DELETE FROM scans WHERE finished_at < ?`, before.UnixNano()); err != nil {
+		return 0, 0, err
+	}
+	if scans, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	// VACUUM can't run in a transaction
+	_, err = r.db.Exec(`VACUUM`)
+	return scans, findings, err
+}
+
+// findingsSince returns the findings recorded at or after since, oldest
+// first. target limits them to scans of that url when set.
+func (r *resultDB) findingsSince(since time.Time, target string) ([]*finding, error) {
+	q := `SELECT f.kind, f.path, f.url, f.time, f.evidence, f.severity, f.message FROM findings f JOIN scans s ON s.id = f.scan_id WHERE 1 = 1`
+	args := []interface{}{}
+	if !since.IsZero() {
+		q += ` AND f.time >= ?`
+		args = append(args, since.UnixNano())
+	}
+	if target != "" {
+		q += ` AND s.target = ?`
+		args = append(args, target)
+	}
+	rows, err := r.db.Query(q+` ORDER BY f.time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []*finding{}
+	for rows.Next() {
+		f := &finding{}
+		var t int64
+		if err := rows.Scan(&f.Kind, &f.Path, &f.URL, &t, &f.Evidence, &f.Severity, &f.Message); err != nil {
+			return nil, err
+		}
+		f.Time = time.Unix(0, t)
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// parseSince parses a duration back from now, which may count days like
+// "7d", or an RFC 3339 time or date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(s, "d") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since: %s", s)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResultDB(t *testing.T) {
+	db, err := openResultDB(filepath.Join(t.TempDir(), "results.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -10)
+	if err := db.saveScan("https://a.example.com", old, old, &stats{requests: 2}, []*finding{
+		{Kind: findingPublished, Path: "./old.php", URL: "https://a.example.com/old.php", Time: old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.saveScan("https://a.example.com", now, now, &stats{requests: 2}, []*finding{
+		{Kind: findingPublished, Path: "./new.php", URL: "https://a.example.com/new.php", Time: now, Evidence: "e.http", Severity: severityHigh},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.saveScan("https://b.example.com", now, now, &stats{}, []*finding{
+		{Kind: findingPublished, Path: "./b.php", URL: "https://b.example.com/b.php", Time: now},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		since    time.Time
+		target   string
+		expected []string
+	}{
+		{time.Time{}, "", []string{"./old.php", "./new.php", "./b.php"}},
+		{now.AddDate(0, 0, -7), "", []string{"./new.php", "./b.php"}},
+		{now.AddDate(0, 0, -7), "https://a.example.com", []string{"./new.php"}},
+	}
+	for _, tt := range tests {
+		fs, err := db.findingsSince(tt.since, tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := []string{}
+		for _, f := range fs {
+			paths = append(paths, f.Path)
+		}
+		if len(paths) != len(tt.expected) {
+			t.Fatalf("expected %v to eq %v", paths, tt.expected)
+		}
+		for i := range paths {
+			if paths[i] != tt.expected[i] {
+				t.Errorf("expected %v to eq %v", paths, tt.expected)
+			}
+		}
+	}
+
+	fs, _ := db.findingsSince(now.Add(-time.Second), "https://a.example.com")
+	if !fs[0].Time.Equal(now) || fs[0].Evidence != "e.http" || fs[0].Severity != severityHigh {
+		t.Errorf("unexpected finding %+v", fs[0])
+	}
+
+	scans, findings, err := db.prune(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scans != 1 || findings != 1 {
+		t.Errorf("expected %d scans and %d findings to be pruned, got %d and %d", 1, 1, scans, findings)
+	}
+	if fs, _ := db.findingsSince(time.Time{}, ""); len(fs) != 2 {
+		t.Errorf("unexpected findings after prune %+v", fs)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"7d":                   time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC),
+		"12h":                  time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		"2024-03-01T00:00:00Z": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for s, expected := range tests {
+		got, err := parseSince(s, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(expected) {
+			t.Errorf("expected %s to eq %s", got, expected)
+		}
+	}
+	if _, err := parseSince("last week", now); err == nil {
+		t.Errorf("expected %q to be an error", "last week")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dedupe fetches a url once when several entries resolve to it, e.g.
+// vendored copies of the same file, and hands the response to all of
+// them. Responses are only kept until every entry expected to use them
+// has done so.
+type dedupe struct {
+	mu sync.Mutex
+	// users counts the entries that have yet to fetch a key.
+	users map[string]int
+	calls map[string]*fetchCall
+}
+
+type fetchCall struct {
+	done chan struct{}
+	r    *Response
+	err  error
+}
+
+func newDedupe() *dedupe {
+	return &dedupe{users: map[string]int{}, calls: map[string]*fetchCall{}}
+}
+
+// expect records that an entry will fetch key.
+func (d *dedupe) expect(key string) {
+	d.mu.Lock()
+	d.users[key]++
+	d.mu.Unlock()
+}
+
+// do returns the response of key, calling fetch unless another entry
+// already did. shared reports whether the response was reused.
+func (d *dedupe) do(key string, fetch func() (*Response, error)) (r *Response, shared bool, err error) {
+	if d == nil {
+		r, err = fetch()
+		return r, false, err
+	}
+
+	d.mu.Lock()
+	c, ok := d.calls[key]
+	if !ok && d.users[key] < 2 {
+		delete(d.users, key)
+		d.mu.Unlock()
+		r, err = fetch()
+		return r, false, err
+	}
+	d.users[key]--
+	if !ok {
+		c = &fetchCall{done: make(chan struct{})}
+		d.calls[key] = c
+	} else if d.users[key] <= 0 {
+		delete(d.users, key)
+		delete(d.calls, key)
+	}
+	d.mu.Unlock()
+
+	if ok {
+		<-c.done
+		return c.r, true, c.err
+	}
+	c.r, c.err = fetch()
+	close(c.done)
+	return c.r, false, c.err
+}
+
+// fetchKey identifies the request of u for e. Entries with their own
+// headers may get a different response for the same url.
+func fetchKey(e *entry, u string) string {
+	if len(e.Headers) == 0 {
+		return u
+	}
+	hs := make([]string, 0, len(e.Headers))
+	for k, v := range e.Headers {
+		hs = append(hs, strings.ToLower(k)+": "+v)
+	}
+	sort.Strings(hs)
+	return u + "\n" + strings.Join(hs, "\n")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// maxDiffInput is how many lines of each side are diffed, which keeps
+	// the quadratic diff of a finding cheap.
+	maxDiffInput = 1000
+	diffContext  = 3
+)
+
+// diff returns a unified diff between the head of the local file of e and
+// the response, with at most s.diffLines lines, or "" when they are the
+// same.
+func (s *scanner) diff(e *entry, u string, r *Response) string {
+	local := e.Head
+	if local == nil {
+		fp, err := os.Open(s.localPath(e))
+		if err != nil {
+			return ""
+		}
+		defer fp.Close()
+		local = diffInput(fp)
+	}
+	remote := diffInput(bytes.NewReader(r.Body))
+	if len(local) < len(remote) && e.Head != nil {
+		// only the head of the local file is known
+		remote = remote[:len(local)]
+	}
+	return unifiedDiff(local, remote, e.Path, u, s.diffLines)
+}
+
+// diffInput returns the first maxDiffInput lines of r.
+func diffInput(r io.Reader) []string {
+	lines := []string{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, initScanTokenSize), MaxScanTokenSize)
+	for sc.Scan() && len(lines) < maxDiffInput {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
+
+// diffOp is a line of a diff: ' ' kept, '-' only in a, '+' only in b.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff of a and b cut after max lines, or
+// "" when they are the same.
+func unifiedDiff(a, b []string, from, to string, max int) string {
+	ops := diffOps(a, b)
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	out := []string{"--- " + from, "+++ " + to}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// a hunk spans the changes less than two contexts apart
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops) && j-end <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		end += diffContext + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		aStart, bStart := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aStart++
+			}
+			if op.kind != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		lines := []string{}
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+			lines = append(lines, string(op.kind)+op.line)
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aLen, bStart, bLen))
+		out = append(out, lines...)
+		i = end
+	}
+
+	if max > 0 && len(out) > max {
+		out = append(out[:max], fmt.Sprintf("... %d more lines", len(out)-max))
+	}
+	return strings.Join(out, "\n")
+}
+
+// diffOps aligns a and b along their longest common subsequence.
+func diffOps(a, b []string) []diffOp {
+	// the common head and tail are skipped, so same files cost nothing
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] is the length of the LCS of ma[i:] and mb[j:]
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			switch {
+			case ma[i] == mb[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, diffOp{' ', ma[i]})
+			i, j = i+1, j+1
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', ma[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', mb[j]})
+			j++
+		}
+	}
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	b := []string{"a", "b", "c", "D", "e", "f", "g", "h", "i", "j", "k"}
+	expected := strings.Join([]string{
+		"--- old",
+		"+++ new",
+		"@@ -1,7 +1,7 @@",
+		" a",
+		" b",
+		" c",
+		"-d",
+		"+D",
+		" e",
+		" f",
+		" g",
+		"@@ -8,3 +8,4 @@",
+		" h",
+		" i",
+		" j",
+		"+k",
+	}, "\n")
+	if got := unifiedDiff(a, b, "old", "new", 0); got != expected {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+
+	if got := unifiedDiff(a, a, "old", "new", 0); got != "" {
+		t.Errorf("expected no diff of the same lines, got %q", got)
+	}
+
+	got := unifiedDiff(a, b, "old", "new", 4)
+	if !strings.HasSuffix(got, "\n... 12 more lines") || strings.Count(got, "\n") != 4 {
+		t.Errorf("expected the diff to be cut after 4 lines, got %q", got)
+	}
+}
+
+func TestScanner_diffLines(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "config.php"), []byte("<?php\n$user = 'app';\n$password = 'new';\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<?php\n$user = 'app';\n$password = 'old';\n$password = 'new';\n")
+	}))
+	defer ts.Close()
+
+	s := &scanner{fetchers: newFetchers(ts.Client(), 3, &tls.Config{}, nil), root: root, diffLines: 20}
+	if err := s.request(context.Background(), &entry{Path: "./config.php", URL: ts.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 {
+		t.Fatalf("unexpected findings %+v", s.findings)
+	}
+	if !strings.Contains(s.findings[0].Diff, "\n+$password = 'old';\n") {
+		t.Errorf("expected the diff to show the old line, got %q", s.findings[0].Diff)
+	}
+}
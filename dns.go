@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsLookupTimeout bounds a shared lookup, which runs apart from the
+// contexts of its callers.
+const dnsLookupTimeout = 30 * time.Second
+
+// dnsCache resolves each host once per ttl and dials its addresses in
+// turn, so scans don't repeat a lookup for every request. The TTLs of the
+// records aren't visible through net.Resolver, so ttl applies to all.
+type dnsCache struct {
+	resolver *net.Resolver
+	dialer   *net.Dialer
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*dnsEntry
+}
+
+// dnsEntry is a lookup, which is done once ready is closed.
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// newDNSCache returns a cache resolving through server, e.g. 1.1.1.1:53,
+// or the system resolver when it is empty. A zero ttl disables caching.
+func newDNSCache(server string, ttl time.Duration, dialer *net.Dialer) (*dnsCache, error) {
+	resolver := net.DefaultResolver
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("invalid dns server: %s", server)
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &dnsCache{resolver: resolver, dialer: dialer, ttl: ttl, hosts: map[string]*dnsEntry{}}, nil
+}
+
+// lookup returns the addresses of host, from the cache while they are
+// fresh. Concurrent lookups of a host share the same query.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	if c.ttl <= 0 {
+		return c.resolver.LookupHost(ctx, host)
+	}
+	c.mu.Lock()
+	e, ok := c.hosts[host]
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) || e.err != nil {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		e = &dnsEntry{ready: make(chan struct{})}
+		c.hosts[host] = e
+		// the query is shared, so it isn't canceled along with the
+		// context of the caller that happens to start it
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+			defer cancel()
+			e.addrs, e.err = c.resolver.LookupHost(ctx, host)
+			e.expires = time.Now().Add(c.ttl)
+			close(e.ready)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// DialContext dials the addresses of the host of addr until one answers.
+func (c *dnsCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return c.dialer.DialContext(ctx, network, addr)
+	}
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return c.dialAddrs(ctx, network, port, addrs)
+}
+
+// dialAddrs dials addrs like the happy eyeballs of net.Dialer does for
+// host names: the addresses of the family of the first one are tried in
+// turn, and the ones of the other family in parallel once the first
+// attempt failed or took longer than the fallback delay.
+func (c *dnsCache) dialAddrs(ctx context.Context, network, port string, addrs []string) (net.Conn, error) {
+	primary, fallback := []string{}, []string{}
+	v4 := net.ParseIP(addrs[0]).To4() != nil
+	for _, a := range addrs {
+		if (net.ParseIP(a).To4() != nil) == v4 {
+			primary = append(primary, a)
+		} else {
+			fallback = append(fallback, a)
+		}
+	}
+	if len(fallback) == 0 {
+		return c.dialSerial(ctx, network, port, primary)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan dialResult, 2)
+	start := func(addrs []string) {
+		go func() {
+			conn, err := c.dialSerial(ctx, network, port, addrs)
+			results <- dialResult{conn, err}
+		}()
+	}
+	delay := c.dialer.FallbackDelay
+	if delay <= 0 {
+		delay = 300 * time.Millisecond
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	start(primary)
+	pending, fallbackStarted := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				start(fallback)
+				pending, fallbackStarted = pending+1, true
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					// the loser may still connect after cancel
+					go func() {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !fallbackStarted {
+				start(fallback)
+				pending, fallbackStarted = pending+1, true
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dialSerial dials addrs in turn until one answers.
+func (c *dnsCache) dialSerial(ctx context.Context, network, port string, addrs []string) (net.Conn, error) {
+	var err error
+	for _, a := range addrs {
+		var conn net.Conn
+		if conn, err = c.dialer.DialContext(ctx, network, net.JoinHostPort(a, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
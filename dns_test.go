@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveDNS answers every A query with 127.0.0.1 and counts them.
+func serveDNS(t *testing.T, queries *int64) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q := buf[:n]
+			end := 12
+			for end < n && q[end] != 0 {
+				end += int(q[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(q[end-4:])
+			res := append([]byte{}, q[:2]...)
+			res = append(res, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+			res = append(res, q[12:end]...)
+			if qtype == 1 {
+				atomic.AddInt64(queries, 1)
+				res[7] = 1
+				res = append(res, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+			}
+			pc.WriteTo(res, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestDNSCache(t *testing.T) {
+	var queries int64
+	server := serveDNS(t, &queries)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	c, err := newDNSCache(server, time.Minute, &net.Dialer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newHTTPClient(3, nil, protoHTTP1, c.DialContext, transportTimeouts{})
+	for i := 0; i < 3; i++ {
+		r, err := client.Get("http://pmr.test:" + port + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		// new connections are dialed every time
+		client.CloseIdleConnections()
+	}
+	if n := atomic.LoadInt64(&queries); n != 1 {
+		t.Errorf("expected %d queries, got %d", 1, n)
+	}
+
+	c.ttl = 0
+	if _, err := c.lookup(context.Background(), "pmr.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&queries); n != 2 {
+		t.Errorf("expected %d queries, got %d", 2, n)
+	}
+}
+
+func TestDNSCache_dialAddrs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	c := &dnsCache{dialer: &net.Dialer{FallbackDelay: 50 * time.Millisecond}}
+	// 100::/64 is a discard prefix, so the first address never answers
+	conn, err := c.dialAddrs(context.Background(), "tcp", port, []string{"100::1", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != l.Addr().String() {
+		t.Errorf("expected %q to eq %q", got, l.Addr().String())
+	}
+}
+
+func TestDNSCache_canceledCaller(t *testing.T) {
+	var queries int64
+	server := serveDNS(t, &queries)
+	release := make(chan struct{})
+	c := &dnsCache{
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				<-release
+				return (&net.Dialer{}).DialContext(ctx, network, server)
+			},
+		},
+		ttl:   time.Minute,
+		hosts: map[string]*dnsEntry{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.lookup(ctx, "pmr.test")
+		first <- err
+	}()
+	for {
+		c.mu.Lock()
+		_, started := c.hosts["pmr.test"]
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := c.lookup(context.Background(), "pmr.test")
+		waiter <- err
+	}()
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("expected %v to eq %v", err, context.Canceled)
+	}
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("expected the waiter not to get the error of the canceled caller, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+type errorClass int
+
+const (
+	errorNetwork errorClass = iota
+	errorDNS
+	errorLocal
+)
+
+// skipPolicy selects the classes of errors that are logged and counted
+// instead of failing the scan.
+type skipPolicy struct {
+	network bool
+	dns     bool
+	local   bool
+}
+
+// errorKind is the cause of a failed check, saved in results and counted
+// in the summary so that the errors of large scans can be told apart
+// without reading their messages.
+type errorKind string
+
+const (
+	errorKindDNS      errorKind = "dns"
+	errorKindConnect  errorKind = "connect"
+	errorKindTLS      errorKind = "tls"
+	errorKindTimeout  errorKind = "timeout"
+	errorKindReset    errorKind = "reset"
+	errorKindProtocol errorKind = "protocol"
+	// errorKindLimit is a body cut off by -max-body-size or -max-expansion.
+	errorKindLimit errorKind = "limit"
+	// errorKindStatus is a response with a status that isn't checked.
+	errorKindStatus errorKind = "status"
+	errorKindLocal  errorKind = "local"
+	errorKindOther  errorKind = "other"
+)
+
+// errorKindOf classifies err by the step of the request that failed.
+func errorKindOf(err error) errorKind {
+	var (
+		dnsErr   *net.DNSError
+		bodyErr  *bodyError
+		timeout  interface{ Timeout() bool }
+		verify   *tls.CertificateVerificationError
+		unknown  x509.UnknownAuthorityError
+		hostname x509.HostnameError
+		invalid  x509.CertificateInvalidError
+		record   tls.RecordHeaderError
+		alert    tls.AlertError
+		opErr    *net.OpError
+		corrupt  flate.CorruptInputError
+		ftpErr   *textproto.Error
+		pathErr  *os.PathError
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return errorKindDNS
+	case errors.As(err, &bodyErr):
+		if bodyErr.timeout {
+			return errorKindTimeout
+		}
+		return errorKindLimit
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &timeout) && timeout.Timeout():
+		return errorKindTimeout
+	case errors.As(err, &verify), errors.As(err, &unknown), errors.As(err, &hostname),
+		errors.As(err, &invalid), errors.As(err, &record), errors.As(err, &alert):
+		return errorKindTLS
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errorKindReset
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return errorKindConnect
+	case errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.As(err, &corrupt),
+		errors.As(err, &ftpErr), strings.Contains(err.Error(), "malformed HTTP"), strings.Contains(err.Error(), "http2:"):
+		return errorKindProtocol
+	case errors.As(err, &pathErr):
+		return errorKindLocal
+	}
+	return errorKindOther
+}
+
+func classifyFetchError(err error) errorClass {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errorDNS
+	}
+	return errorNetwork
+}
+
+// skipError counts err and returns whether the scan should go on.
+func (s *scanner) skipError(class errorClass, err error) bool {
+	atomic.AddInt64(&s.stats.errors, 1)
+	skip := false
+	switch class {
+	case errorNetwork:
+		atomic.AddInt64(&s.stats.networkErrors, 1)
+		skip = s.skip.network
+	case errorDNS:
+		atomic.AddInt64(&s.stats.dnsErrors, 1)
+		skip = s.skip.dns
+	case errorLocal:
+		atomic.AddInt64(&s.stats.localErrors, 1)
+		skip = s.skip.local
+	}
+	if skip {
+		logrus.Error(err)
+	}
+	return skip
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorKindOf(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/reset", "/garbage":
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			if r.URL.Path == "/garbage" {
+				buf.WriteString("HELLO\r\n\r\n")
+				buf.Flush()
+			}
+			conn.Close()
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer ts.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		u        string
+		expected errorKind
+		// only the timeout case runs with a short timeout, so that the
+		// others aren't classified as timeouts on a slow run, e.g. -race
+		timeout time.Duration
+	}{
+		{ts.URL + "/slow", errorKindTimeout, 100 * time.Millisecond},
+		{ts.URL + "/reset", errorKindReset, 0},
+		{ts.URL + "/garbage", errorKindProtocol, 0},
+		{ts.URL + "/large", errorKindLimit, 0},
+		{tlsServer.URL, errorKindTLS, 0},
+		{closed, errorKindConnect, 0},
+	}
+	for _, tt := range tests {
+		timeout := tt.timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		f := &httpFetcher{client: &http.Client{Timeout: timeout}, limits: bodyLimits{maxSize: 1024}}
+		_, err := f.Fetch(context.Background(), &entry{}, tt.u)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.u)
+		}
+		if got := errorKindOf(err); got != tt.expected {
+			t.Errorf("%s: expected %q to eq %q (%s)", tt.u, got, tt.expected, err)
+		}
+	}
+
+	if got := errorKindOf(&net.DNSError{Err: "no such host", Name: "example.invalid"}); got != errorKindDNS {
+		t.Errorf("expected %q to eq %q", got, errorKindDNS)
+	}
+	_, err = os.Open("/nonexistent/file")
+	if got := errorKindOf(err); got != errorKindLocal {
+		t.Errorf("expected %q to eq %q", got, errorKindLocal)
+	}
+}
+
+func TestStats_errorKindSummary(t *testing.T) {
+	st := &stats{}
+	for _, k := range []errorKind{errorKindReset, errorKindTimeout, errorKindTimeout, errorKindDNS} {
+		st.addErrorKind(k)
+	}
+	expected := "timeout 2, dns 1, reset 1"
+	if got := st.errorKindSummary(); got != expected {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const esTimeout = 30 * time.Second
+
+// esDocument is a finding as indexed into Elasticsearch.
+type esDocument struct {
+	*finding
+	Target    string    `json:"target"`
+	StartedAt time.Time `json:"scan_started_at"`
+	Scanner   string    `json:"scanner"`
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulkIndex indexes findings into index with the _bulk API of an
+// Elasticsearch or OpenSearch cluster. Credentials may be given in the
+// userinfo of esURL.
+func bulkIndex(esURL, index, target string, started time.Time, findings []*finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	for _, f := range findings {
+		action := map[string]map[string]string{"index": {"_index": index}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		doc := &esDocument{finding: f, Target: target, StartedAt: started, Scanner: Name + "/" + Version}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(esURL, "/")+"/_bulk", b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	client := &http.Client{Timeout: esTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("bulk index: %s", res.Status)
+	}
+
+	br := &esBulkResponse{}
+	if err := json.NewDecoder(res.Body).Decode(br); err != nil {
+		return fmt.Errorf("bulk index: %s", err)
+	}
+	if br.Errors {
+		for _, item := range br.Items {
+			for _, r := range item {
+				if r.Error.Type != "" {
+					return fmt.Errorf("bulk index: %s: %s", r.Error.Type, r.Error.Reason)
+				}
+			}
+		}
+		return fmt.Errorf("bulk index failed")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBulkIndex(t *testing.T) {
+	lines := []map[string]interface{}{}
+	var user string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user, _, _ = r.BasicAuth()
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			l := map[string]interface{}{}
+			json.Unmarshal(sc.Bytes(), &l)
+			lines = append(lines, l)
+		}
+		fmt.Fprint(w, `{"errors": false, "items": []}`)
+	}))
+	defer es.Close()
+
+	started := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	findings := []*finding{{Kind: findingPublished, Path: "./a.php", URL: "https://example.com/a.php", Time: started}}
+	u := "http://elastic:secret@" + es.Listener.Addr().String()
+	if err := bulkIndex(u, "pmr-findings", "https://example.com", started, findings); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || user != "elastic" {
+		t.Fatalf("unexpected bulk body %v from %q", lines, user)
+	}
+	if idx := lines[0]["index"].(map[string]interface{})["_index"]; idx != "pmr-findings" {
+		t.Errorf("expected %q to eq %q", idx, "pmr-findings")
+	}
+	if lines[1]["url"] != "https://example.com/a.php" || lines[1]["target"] != "https://example.com" {
+		t.Errorf("unexpected document %v", lines[1])
+	}
+}
+
+func TestBulkIndex_errors(t *testing.T) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errors": true, "items": [{"index": {"status": 400, "error": {"type": "mapper_parsing_exception", "reason": "bad"}}}]}`)
+	}))
+	defer es.Close()
+
+	err := bulkIndex(es.URL, "pmr", "", time.Now(), []*finding{{Kind: findingPublished}})
+	if err == nil || err.Error() != "bulk index: mapper_parsing_exception: bad" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
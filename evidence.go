@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// evidenceFile returns where the evidence of f is saved in dir. The name
+// only depends on the finding, so a later scan overwrites the evidence of
+// the same exposure instead of piling up copies.
+func evidenceFile(dir string, f *finding) string {
+	sum := sha256.Sum256([]byte(f.key()))
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.http", f.Kind, hex.EncodeToString(sum[:8])))
+}
+
+// writeEvidence saves the request for u and the response r as text, the
+// way they would appear on the wire. A body cut at maxBodyBuffer ends
+// with a marker telling its full size.
+func writeEvidence(path, u string, r *Response) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fp)
+	fmt.Fprintf(w, "GET %s\n", u)
+	writeHeader(w, r.RequestHeader)
+	fmt.Fprintln(w)
+	proto := r.Proto
+	if proto == "" {
+		proto = "-"
+	}
+	fmt.Fprintf(w, "%s %s\n", proto, r.Status)
+	writeHeader(w, r.Header)
+	fmt.Fprintln(w)
+	w.Write(r.Body)
+	if r.truncated() {
+		fmt.Fprintf(w, "\n[pmr: body truncated at %d of %d bytes]\n", len(r.Body), r.Size)
+	}
+
+	if err := w.Flush(); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+func writeHeader(w *bufio.Writer, h map[string][]string) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+}
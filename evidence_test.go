@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanner_evidence(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "origin")
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	dir := filepath.Join(t.TempDir(), "evidence")
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root, evidenceDir: dir}
+	e := &entry{Path: "./secret.php", URL: target.URL, Headers: map[string]string{"Host": "example.com"}}
+	if err := s.request(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 {
+		t.Fatalf("unexpected findings %+v", s.findings)
+	}
+	f := s.findings[0]
+	if f.Evidence != evidenceFile(dir, f) {
+		t.Errorf("expected %q to eq %q", f.Evidence, evidenceFile(dir, f))
+	}
+
+	b, err := os.ReadFile(f.Evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"GET " + target.URL + "/secret.php\n",
+		"Host: example.com\n",
+		"User-Agent: PyamaMultiRequest/",
+		"HTTP/1.1 200 OK\n",
+		"X-Served-By: origin\n",
+		"\n\n<?php secret",
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %q to contain %q", string(b), expected)
+		}
+	}
+}
+
+func TestWriteEvidence_truncated(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "published.http")
+	r := &Response{Proto: "HTTP/1.1", Status: "200 OK", Body: []byte("head"), Size: 2 << 20}
+	if err := writeEvidence(p, "https://example.com/dump.sql", r); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n\nhead\n[pmr: body truncated at 4 of 2097152 bytes]\n"
+	if !strings.HasSuffix(string(b), expected) {
+		t.Errorf("expected %q to end with %q", string(b), expected)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Response is the protocol independent result of a fetch. Non-HTTP
+// fetchers map their replies onto the closest HTTP status code so the
+// comparison logic stays the same for every protocol.
+type Response struct {
+	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
+	// Body holds the first maxBodyBuffer bytes of the body.
+	Body []byte
+	Size int64
+	// RequestHeader holds the headers that were sent, if the protocol
+	// has any.
+	RequestHeader http.Header
+}
+
+// truncated reports whether Body holds only the head of the body.
+func (r *Response) truncated() bool {
+	return int64(len(r.Body)) < r.Size
+}
+
+// Fetcher retrieves the remote content for an entry.
+type Fetcher interface {
+	Fetch(ctx context.Context, e *entry, u string) (*Response, error)
+}
+
+// fetchers maps URL schemes to the Fetcher handling them.
+type fetchers map[string]Fetcher
+
+func newFetchers(client *http.Client, timeout int, tlsConfig *tls.Config, report *tlsReport) fetchers {
+	hf := &httpFetcher{client: client, tlsReport: report, limits: defaultBodyLimits}
+	fs := fetchers{
+		"http":  hf,
+		"https": hf,
+		"ftp":   &ftpFetcher{timeout: timeout, tlsConfig: tlsConfig},
+		"ftps":  &ftpFetcher{timeout: timeout, tlsConfig: tlsConfig, implicitTLS: true},
+	}
+	for scheme, p := range fetcherPlugins {
+		fs[scheme] = p
+	}
+	return fs
+}
+
+// setDial makes the fetchers of fs dial through dial.
+func (fs fetchers) setDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	for _, f := range fs {
+		if ff, ok := f.(*ftpFetcher); ok {
+			ff.dial = dial
+		}
+	}
+}
+
+// setBodyLimits sets the limits of the http(s) fetchers of fs.
+func (fs fetchers) setBodyLimits(l bodyLimits) {
+	for _, f := range fs {
+		if hf, ok := f.(*httpFetcher); ok {
+			hf.limits = l
+		}
+	}
+}
+
+// setCredentials makes the fetchers of fs ask h for the credentials of
+// every host.
+func (fs fetchers) setCredentials(h *credentialHelper) {
+	for _, f := range fs {
+		switch ff := f.(type) {
+		case *httpFetcher:
+			ff.credentials = h
+		case *ftpFetcher:
+			ff.credentials = h
+		}
+	}
+}
+
+func (fs fetchers) forURL(u string) (Fetcher, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fs[strings.ToLower(pu.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme: %s", u)
+	}
+	return f, nil
+}
+
+const (
+	protoAuto  = ""
+	protoHTTP1 = "HTTP/1.1"
+	protoHTTP2 = "HTTP/2"
+)
+
+// transportTimeouts bound steps of a request separately from the overall
+// request timeout, so that e.g. a slow TLS handshake can be waited for
+// without waiting as long for everything else. Zero values leave a step
+// bounded by the request timeout only.
+type transportTimeouts struct {
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+}
+
+// newHTTPClient builds the client used for every HTTP request. A custom
+// TLS config disables HTTP/2 unless it is explicitly forced with proto.
+// Connections are made with dial, or a plain net.Dialer when it is nil.
+func newHTTPClient(timeout int, tlsConfig *tls.Config, proto string, dial func(ctx context.Context, network, addr string) (net.Conn, error), tt transportTimeouts) *http.Client {
+	tr := &http.Transport{
+		TLSClientConfig:       tlsConfig,
+		DialContext:           dial,
+		TLSHandshakeTimeout:   tt.tlsHandshake,
+		ResponseHeaderTimeout: tt.responseHeader,
+	}
+	switch proto {
+	case protoHTTP1:
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case protoHTTP2:
+		tr.ForceAttemptHTTP2 = true
+	}
+	return &http.Client{
+		Transport: tr,
+		Timeout:   time.Duration(timeout) * time.Second,
+	}
+}
+
+func newRequest(ctx context.Context, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	ua := fmt.Sprintf("%s/%s", "PyamaMultiRequest", Version)
+	req.Header.Set("User-Agent", ua)
+	return req, nil
+}
+
+type httpFetcher struct {
+	client *http.Client
+	// tlsReport records server certificates when set.
+	tlsReport *tlsReport
+	limits    bodyLimits
+	// credentials authorize requests without an Authorization header
+	// when set.
+	credentials *credentialHelper
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, e *entry, u string) (*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	authorized := false
+	if f.credentials != nil && req.Header.Get("Authorization") == "" {
+		c, err := f.credentials.get(ctx, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			c.authorize(req)
+			authorized = true
+		}
+	}
+	// gzip is asked for and decompressed here instead of by the transport,
+	// which hides how much was sent, to tell how much a body expands
+	gunzip := f.limits.maxRatio > 0 && req.Header.Get("Accept-Encoding") == ""
+	if gunzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	r, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if f.tlsReport != nil {
+		f.tlsReport.record(r.Request.URL.Host, r.TLS)
+	}
+
+	br := io.Reader(r.Body)
+	if f.limits.idle > 0 {
+		ir := newIdleReader(br, f.limits.idle, cancel)
+		defer ir.stop()
+		br = ir
+	}
+	g := &guardedReader{r: br, limits: f.limits}
+	if gunzip && strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		g.compressed = &countingReader{r: br}
+		zr, err := gzip.NewReader(g.compressed)
+		switch {
+		case err == io.EOF:
+			// an empty body
+			g.r = bytes.NewReader(nil)
+		case err != nil:
+			return nil, fmt.Errorf("%s: %w", u, err)
+		default:
+			defer zr.Close()
+			g.r = zr
+		}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+	}
+	body, size, err := readBody(ctx, g)
+	if err != nil {
+		if _, ok := err.(*url.Error); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	reqHeader := req.Header.Clone()
+	if req.Host != "" {
+		reqHeader.Set("Host", req.Host)
+	}
+	if authorized {
+		reqHeader.Set("Authorization", redacted)
+	}
+	return &Response{
+		StatusCode:    r.StatusCode,
+		Status:        r.Status,
+		Proto:         r.Proto,
+		Header:        r.Header,
+		Body:          body,
+		Size:          size,
+		RequestHeader: reqHeader,
+	}, nil
+}
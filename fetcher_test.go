@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient_proto(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	for proto, expected := range map[string]int{protoAuto: 1, protoHTTP1: 1, protoHTTP2: 2} {
+		client := newHTTPClient(3, &tls.Config{InsecureSkipVerify: true}, proto, nil, transportTimeouts{})
+		r, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		if r.ProtoMajor != expected {
+			t.Errorf("%q: expected %d to eq %d", proto, r.ProtoMajor, expected)
+		}
+	}
+}
+
+func TestHTTPFetcher_limits(t *testing.T) {
+	gz := func(b []byte) []byte {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}
+	page, bomb := gz([]byte("hello")), gz(make([]byte, 10<<20))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(page)
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(bomb)
+		case "/large":
+			w.Write(make([]byte, 2048))
+		case "/slow":
+			w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(3 * time.Second):
+			}
+		}
+	}))
+	defer ts.Close()
+
+	f := &httpFetcher{client: ts.Client(), limits: bodyLimits{idle: 100 * time.Millisecond, maxSize: 1024, maxRatio: 100}}
+	r, err := f.Fetch(context.Background(), &entry{}, ts.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Body) != "hello" || r.Header.Get("Content-Encoding") != "" {
+		t.Errorf("expected %q to eq %q", r.Body, "hello")
+	}
+
+	f.limits.maxSize = 0
+	for p, expect := range map[string]string{
+		"/bomb":  "compressed body expands more than 100 times",
+		"/slow":  "no body data for 100ms",
+		"/large": "",
+	} {
+		_, err := f.Fetch(context.Background(), &entry{}, ts.URL+p)
+		if expect == "" && err != nil || expect != "" && (err == nil || !strings.Contains(err.Error(), expect)) {
+			t.Errorf("expected %s to fail with %q, got %v", p, expect, err)
+		}
+	}
+	f.limits.maxSize = 1024
+	if _, err := f.Fetch(context.Background(), &entry{}, ts.URL+"/large"); err == nil || !strings.Contains(err.Error(), "body larger than 1024 bytes") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewHTTPClient_timeouts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := newHTTPClient(3, nil, protoAuto, nil, transportTimeouts{responseHeader: 50 * time.Millisecond})
+	if _, err := client.Get(ts.URL); err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
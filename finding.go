@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	findingPublished  = "published"
+	findingUnexpected = "unexpected"
+	findingUnverified = "unverified"
+	findingSource     = "source"
+	findingListing    = "listing"
+	findingVCS        = "vcs"
+	findingSourceMap  = "sourcemap"
+	findingDivergent  = "divergent"
+	findingHeader     = "header"
+	findingMismatch   = "mismatch"
+)
+
+// finding is an exposed file as saved by `scan -output` and read back by
+// the report and baseline commands. Files hold one JSON object per line.
+type finding struct {
+	Kind string    `json:"kind"`
+	Path string    `json:"path"`
+	URL  string    `json:"url"`
+	Time time.Time `json:"time"`
+	// Evidence is the file the response was saved to with -evidence-dir.
+	Evidence string `json:"evidence,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	// Message is the message of the severity rule that matched.
+	Message string `json:"message,omitempty"`
+	// Diff is the unified diff between the local file and the response
+	// with -diff-lines.
+	Diff string `json:"diff,omitempty"`
+}
+
+// key identifies the same exposure across scans.
+func (f *finding) key() string {
+	return f.Kind + " " + f.URL
+}
+
+// describe returns what f means in words, and whether it is an "error" or
+// only a "warning". Kinds of matcher plugins are described by their name.
+func (f *finding) describe() (string, string) {
+	switch f.Kind {
+	case findingPublished:
+		return "error", fmt.Sprintf("This file is published at %s", f.URL)
+	case findingUnexpected:
+		return "error", fmt.Sprintf("This file is served but not in the publish set at %s", f.URL)
+	case findingSourceMap:
+		return "error", fmt.Sprintf("This source map is published at %s", f.URL)
+	case findingVCS:
+		return "error", fmt.Sprintf("This version control metadata is published at %s", f.URL)
+	case findingDivergent:
+		return "error", fmt.Sprintf("This file is served differently by the compared targets at %s", f.URL)
+	case findingMismatch:
+		return "error", fmt.Sprintf("This file doesn't match the checksum manifest at %s", f.URL)
+	case findingHeader:
+		return "error", fmt.Sprintf("This file is served with a header of a rule at %s", f.URL)
+	case findingListing:
+		return "error", fmt.Sprintf("This directory is listed at %s", f.URL)
+	case findingSource:
+		return "error", fmt.Sprintf("This script is served as source instead of being run at %s", f.URL)
+	case findingUnverified:
+		return "warning", fmt.Sprintf("This file may be published at %s, the local file can't be read to compare", f.URL)
+	}
+	return "error", fmt.Sprintf("This file is reported as %s at %s", f.Kind, f.URL)
+}
+
+func readFindings(path string) ([]*finding, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	findings := []*finding{}
+	scanner := bufio.NewScanner(fp)
+	for i := 1; scanner.Scan(); i++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		f := &finding{}
+		if err := json.Unmarshal(scanner.Bytes(), f); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, i, err)
+		}
+		findings = append(findings, f)
+	}
+	return findings, scanner.Err()
+}
+
+func writeFindings(w io.Writer, findings []*finding) error {
+	enc := json.NewEncoder(w)
+	for _, f := range findings {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFindingsFile(path string, findings []*finding) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeFindings(fp, findings); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ftpFetcher retrieves files with RETR over a fresh control connection per
+// fetch. Like curl, ftps:// means implicit TLS on both connections.
+type ftpFetcher struct {
+	timeout     int
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	// dial replaces a plain net.Dialer when set.
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// credentials log in to hosts without a user in the url when set.
+	credentials *credentialHelper
+}
+
+func (f *ftpFetcher) Fetch(ctx context.Context, e *entry, u string) (*Response, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	addr := pu.Host
+	if pu.Port() == "" {
+		port := "21"
+		if f.implicitTLS {
+			port = "990"
+		}
+		addr = net.JoinHostPort(pu.Hostname(), port)
+	}
+
+	timeout := time.Duration(f.timeout) * time.Second
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dial := (&net.Dialer{}).DialContext
+	if f.dial != nil {
+		dial = f.dial
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	tlsConfig := f.tlsConfig.Clone()
+	tlsConfig.ServerName = pu.Hostname()
+
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline)
+	if f.implicitTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c := textproto.NewConn(conn)
+	defer c.Close()
+
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return nil, err
+	}
+
+	user, pass := "anonymous", "anonymous@"
+	if pu.User != nil {
+		user = pu.User.Username()
+		if p, ok := pu.User.Password(); ok {
+			pass = p
+		}
+	} else if f.credentials != nil {
+		cred, err := f.credentials.get(ctx, pu)
+		if err != nil {
+			return nil, err
+		}
+		if cred != nil && cred.login != "" {
+			user, pass = cred.login, cred.password
+		}
+	}
+	code, msg, err := ftpCmd(c, "USER %s", user)
+	if err == nil && code == 331 {
+		code, msg, err = ftpCmd(c, "PASS %s", pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code != 230 {
+		return ftpResponse(code, msg), nil
+	}
+
+	cmds := []string{"TYPE I"}
+	if f.implicitTLS {
+		cmds = append([]string{"PBSZ 0", "PROT P"}, cmds...)
+	}
+	for _, cmd := range cmds {
+		if code, msg, err := ftpCmd(c, cmd); err != nil {
+			return nil, err
+		} else if code != 200 {
+			return nil, fmt.Errorf("%s: %d %s", cmd, code, msg)
+		}
+	}
+
+	dataAddr, err := ftpPassive(c, pu.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	dc, err := dial(ctx, "tcp", dataAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+	dc.SetDeadline(deadline)
+	if f.implicitTLS {
+		dc = tls.Client(dc, tlsConfig)
+	}
+
+	code, msg, err = ftpCmd(c, "RETR %s", strings.TrimPrefix(pu.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if code != 125 && code != 150 {
+		return ftpResponse(code, msg), nil
+	}
+	body, size, err := readBody(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	dc.Close()
+
+	code, msg, err = c.ReadResponse(0)
+	if err != nil {
+		return nil, err
+	}
+	if code != 226 && code != 250 {
+		return ftpResponse(code, msg), nil
+	}
+	ftpCmd(c, "QUIT")
+
+	r := ftpResponse(code, msg)
+	r.Body, r.Size = body, size
+	return r, nil
+}
+
+func ftpCmd(c *textproto.Conn, format string, args ...interface{}) (int, string, error) {
+	id, err := c.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.StartResponse(id)
+	defer c.EndResponse(id)
+	return c.ReadResponse(0)
+}
+
+// ftpPassive opens a passive data channel and returns its address. The
+// host of the control connection is always used, since servers behind NAT
+// often advertise an unreachable address in their PASV reply.
+func ftpPassive(c *textproto.Conn, host string) (string, error) {
+	code, msg, err := ftpCmd(c, "EPSV")
+	if err != nil {
+		return "", err
+	}
+	if code == 229 {
+		i, j := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if i >= 0 && j > i+4 {
+			return net.JoinHostPort(host, msg[i+4:j]), nil
+		}
+	}
+
+	code, msg, err = ftpCmd(c, "PASV")
+	if err != nil {
+		return "", err
+	}
+	i, j := strings.Index(msg, "("), strings.Index(msg, ")")
+	if code != 227 || i < 0 || j < i {
+		return "", fmt.Errorf("PASV: %d %s", code, msg)
+	}
+	parts := strings.Split(msg[i+1:j], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("PASV: %d %s", code, msg)
+	}
+	p1, err := strconv.Atoi(strings.TrimSpace(parts[4]))
+	if err != nil {
+		return "", err
+	}
+	p2, err := strconv.Atoi(strings.TrimSpace(parts[5]))
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p1*256+p2)), nil
+}
+
+// ftpResponse maps an FTP reply onto the closest HTTP status code.
+func ftpResponse(code int, msg string) *Response {
+	r := &Response{Status: fmt.Sprintf("%d %s", code, msg)}
+	switch {
+	case code == 226 || code == 250:
+		r.StatusCode = http.StatusOK
+	case code == 550:
+		r.StatusCode = http.StatusNotFound
+	case code == 530 || code == 532:
+		r.StatusCode = http.StatusForbidden
+	default:
+		r.StatusCode = http.StatusBadGateway
+	}
+	return r
+}
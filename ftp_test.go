@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// serveFTP runs a minimal passive-mode FTP server holding files.
+func serveFTP(t *testing.T, files map[string]string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var data net.Listener
+				fmt.Fprint(conn, "220 ready\r\n")
+				r := bufio.NewReader(conn)
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.SplitN(strings.TrimSpace(l), " ", 2)
+					switch cmd[0] {
+					case "USER":
+						fmt.Fprint(conn, "331 password please\r\n")
+					case "PASS":
+						fmt.Fprint(conn, "230 logged in\r\n")
+					case "TYPE":
+						fmt.Fprint(conn, "200 ok\r\n")
+					case "EPSV":
+						data, _ = net.Listen("tcp", "127.0.0.1:0")
+						fmt.Fprintf(conn, "229 Entering Extended Passive Mode (|||%d|)\r\n", data.Addr().(*net.TCPAddr).Port)
+					case "RETR":
+						dc, err := data.Accept()
+						data.Close()
+						if err != nil {
+							return
+						}
+						body, ok := files[cmd[1]]
+						if !ok {
+							dc.Close()
+							fmt.Fprint(conn, "550 no such file\r\n")
+							continue
+						}
+						fmt.Fprint(conn, "150 opening\r\n")
+						fmt.Fprint(dc, body)
+						dc.Close()
+						fmt.Fprint(conn, "226 done\r\n")
+					case "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "502 not implemented\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestFTPFetcher(t *testing.T) {
+	addr := serveFTP(t, map[string]string{"www/index.php": "<?php\necho 1;\n"})
+	f := &ftpFetcher{timeout: 3, tlsConfig: &tls.Config{}}
+
+	r, err := f.Fetch(context.Background(), &entry{}, "ftp://"+addr+"/www/index.php")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusOK || string(r.Body) != "<?php\necho 1;\n" {
+		t.Errorf("unexpected response %d %q", r.StatusCode, r.Body)
+	}
+
+	r, err = f.Fetch(context.Background(), &entry{}, "ftp://"+addr+"/www/missing.php")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d to eq %d", r.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// gate bounds the number of requests in flight. Unlike a buffered channel
+// its limit can be changed while scanning, and it can be paused.
+type gate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	paused bool
+}
+
+func newGate(ctx context.Context, limit int) *gate {
+	g := &gate{limit: limit}
+	g.cond = sync.NewCond(&g.mu)
+	go func() {
+		<-ctx.Done()
+		g.mu.Lock()
+		g.cond.Broadcast()
+		g.mu.Unlock()
+	}()
+	return g
+}
+
+// acquire blocks until a slot is free and the gate is not paused. It
+// returns false when ctx is done before that happens.
+func (g *gate) acquire(ctx context.Context) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for (g.paused || g.active >= g.limit) && ctx.Err() == nil {
+		g.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	g.active++
+	return true
+}
+
+func (g *gate) release() {
+	g.mu.Lock()
+	g.active--
+	g.cond.Broadcast()
+	g.mu.Unlock()
+}
+
+func (g *gate) setLimit(n int) {
+	if n < 1 {
+		n = 1
+	}
+	g.mu.Lock()
+	g.limit = n
+	g.cond.Broadcast()
+	g.mu.Unlock()
+}
+
+func (g *gate) setPaused(paused bool) {
+	g.mu.Lock()
+	g.paused = paused
+	g.cond.Broadcast()
+	g.mu.Unlock()
+}
+
+func (g *gate) state() (limit, active int, paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit, g.active, g.paused
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultErrorTitles are title words of error and maintenance pages that
+// sites serve with 200.
+const defaultErrorTitles = "not found,404,error,maintenance,unavailable,coming soon"
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// genericPages tells the error, maintenance and shell pages a site serves
+// for any path apart from real files. A page is generic when at least
+// threshold urls of the scan answer with it, or when its title has one of
+// titles in it. Findings served with one are downgraded to info, since
+// the local file likely only shares lines with the page.
+type genericPages struct {
+	threshold int
+	titles    []string
+
+	mu sync.Mutex
+	// urls are the urls answering with each page, up to threshold.
+	urls map[string]map[string]bool
+	// findings are the findings served with each page, to downgrade the
+	// ones reported before the page was found to be generic.
+	findings map[string][]*finding
+}
+
+// newGenericPages returns nil when threshold is 0, as the heuristics can
+// downgrade real findings and are only used when asked for.
+func newGenericPages(threshold int, titles string) *genericPages {
+	if threshold <= 0 {
+		return nil
+	}
+	g := &genericPages{threshold: threshold, urls: map[string]map[string]bool{}, findings: map[string][]*finding{}}
+	for _, t := range strings.Split(titles, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			g.titles = append(g.titles, t)
+		}
+	}
+	return g
+}
+
+func pageTitle(body []byte) string {
+	m := titlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// pageFingerprint identifies the page of r as the same across urls. Pages
+// with a title are told apart by it and their rough length, others by
+// their content without the path, which error pages often echo.
+func pageFingerprint(u string, r *Response) string {
+	if t := pageTitle(r.Body); t != "" {
+		return fmt.Sprintf("title %s %d", strings.ToLower(t), r.Size/256)
+	}
+	body := r.Body
+	if pu, err := url.Parse(u); err == nil && pu.Path != "" && pu.Path != "/" {
+		body = bytes.Replace(body, []byte(pu.Path), nil, -1)
+	}
+	return fmt.Sprintf("body %x", sha256.Sum256(body))
+}
+
+// observe counts u as answering with the page of r, whatever its status
+// of 200, 403 or 404, since sites serve the same error page with any of
+// them. It returns the fingerprint of the page.
+func (g *genericPages) observe(u string, r *Response) string {
+	fp := pageFingerprint(u, r)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	us, ok := g.urls[fp]
+	if !ok {
+		us = map[string]bool{}
+		g.urls[fp] = us
+	}
+	if len(us) < g.threshold {
+		us[u] = true
+	}
+	return fp
+}
+
+// reason returns why the page of r, of fingerprint fp, is generic, or ""
+// when it isn't.
+func (g *genericPages) reason(fp string, r *Response) string {
+	title := strings.ToLower(pageTitle(r.Body))
+	for _, t := range g.titles {
+		if title != "" && strings.Contains(title, t) {
+			return fmt.Sprintf("likely an error page, its title is %q", pageTitle(r.Body))
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.urls[fp]) >= g.threshold {
+		return fmt.Sprintf("likely a generic page, at least %d urls answer with the same page", g.threshold)
+	}
+	return ""
+}
+
+// downgrade sets f to info when the page it was served with is generic,
+// and keeps it to downgrade later otherwise.
+func (g *genericPages) downgrade(f *finding, fp string, r *Response) {
+	if reason := g.reason(fp, r); reason != "" {
+		f.Severity, f.Message = severityInfo, reason
+		return
+	}
+	g.mu.Lock()
+	g.findings[fp] = append(g.findings[fp], f)
+	g.mu.Unlock()
+}
+
+// downgradeLate downgrades the findings reported before their page was
+// found to be generic, once the scan is done, and returns how many.
+func (g *genericPages) downgradeLate() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for fp, fs := range g.findings {
+		if len(g.urls[fp]) < g.threshold {
+			continue
+		}
+		for _, f := range fs {
+			f.Severity = severityInfo
+			f.Message = fmt.Sprintf("likely a generic page, at least %d urls answer with the same page", g.threshold)
+			logrus.Infof("%s is served with a generic page, downgraded to %s", f.URL, severityInfo)
+			n++
+		}
+	}
+	return n
+}
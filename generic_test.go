@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanner_genericPages(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 12; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%d.html", i)), []byte("<html>\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "missing.html"), []byte("<html>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.html" {
+			fmt.Fprint(w, "<html>\n<title>Page Not Found</title>")
+			return
+		}
+		// a single page app answers with its shell for any path
+		fmt.Fprintf(w, "<html>\n<title>App</title><p>%s</p>", r.URL.Path)
+	}))
+	defer ts.Close()
+
+	s := &scanner{fetchers: newFetchers(ts.Client(), 3, &tls.Config{}, nil), root: root, generic: newGenericPages(10, defaultErrorTitles)}
+	for i := 0; i < 12; i++ {
+		if err := s.request(context.Background(), &entry{Path: fmt.Sprintf("./%d.html", i), URL: ts.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.request(context.Background(), &entry{Path: "./missing.html", URL: ts.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.generic.downgradeLate(); n != 9 {
+		t.Errorf("expected %d to eq %d", n, 9)
+	}
+	if len(s.findings) != 13 {
+		t.Fatalf("unexpected findings %+v", s.findings)
+	}
+	for _, f := range s.findings {
+		if f.Severity != severityInfo {
+			t.Errorf("expected %s to be downgraded to %s, got %s", f.URL, severityInfo, f.Severity)
+		}
+	}
+}
+
+func TestNewGenericPages(t *testing.T) {
+	if g := newGenericPages(0, defaultErrorTitles); g != nil {
+		t.Errorf("expected the heuristics to be off without a threshold, got %+v", g)
+	}
+	if n := (*genericPages)(nil).downgradeLate(); n != 0 {
+		t.Errorf("expected %d to eq %d", n, 0)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatText   = "text"
+	formatGitHub = "github"
+	formatNDJSON = "ndjson"
+)
+
+// writeAnnotations prints findings as GitHub Actions workflow commands, so
+// they show up as annotations on the files of a pull request.
+func writeAnnotations(w io.Writer, findings []*finding) {
+	for _, f := range findings {
+		level, msg := f.describe()
+		fmt.Fprintf(w, "::%s file=%s,title=%s::%s\n", level, escapeProperty(strings.TrimPrefix(f.Path, "./")), escapeProperty(Name+" "+f.Kind), escapeData(msg))
+	}
+}
+
+// setGitHubOutput sets an output of the current step through the file
+// named by $GITHUB_OUTPUT. Nothing is done outside of GitHub Actions.
+func setGitHubOutput(name, value string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+	fp, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(fp, "%s=%s\n", name, value); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+var (
+	dataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func escapeData(s string) string {
+	return dataEscaper.Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return propertyEscaper.Replace(s)
+}
+
+const (
+	githubAPI = "https://api.github.com"
+	// commentMarker finds the comment of an earlier report to update it.
+	commentMarker = "<!-- " + Name + " report -->"
+)
+
+// githubPR is a pull request given as owner/repo#number.
+type githubPR struct {
+	repo   string
+	number int
+}
+
+func parseGitHubPR(s string) (*githubPR, error) {
+	i := strings.LastIndex(s, "#")
+	if i < 0 || strings.Count(s[:i], "/") != 1 || strings.HasPrefix(s, "/") || s[i-1] == '/' {
+		return nil, fmt.Errorf("invalid pull request %q, expected owner/repo#number", s)
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid pull request %q, expected owner/repo#number", s)
+	}
+	return &githubPR{repo: s[:i], number: n}, nil
+}
+
+type githubComment struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// githubClient posts report comments through the GitHub REST API.
+type githubClient struct {
+	client *http.Client
+	api    string
+	token  string
+}
+
+// comment posts body on pr, or replaces the comment of an earlier report.
+func (c *githubClient) comment(pr *githubPR, body string) error {
+	body = commentMarker + "\n" + body
+	for page := 1; ; page++ {
+		comments := []*githubComment{}
+		u := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=100&page=%d", c.api, pr.repo, pr.number, page)
+		if err := c.do(http.MethodGet, u, nil, http.StatusOK, &comments); err != nil {
+			return err
+		}
+		for _, cm := range comments {
+			if strings.HasPrefix(cm.Body, commentMarker) {
+				u := fmt.Sprintf("%s/repos/%s/issues/comments/%d", c.api, pr.repo, cm.ID)
+				return c.do(http.MethodPatch, u, &githubComment{Body: body}, http.StatusOK, nil)
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+	u := fmt.Sprintf("%s/repos/%s/issues/%d/comments", c.api, pr.repo, pr.number)
+	return c.do(http.MethodPost, u, &githubComment{Body: body}, http.StatusCreated, nil)
+}
+
+func (c *githubClient) do(method, u string, in interface{}, status int, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		e := struct {
+			Message string `json:"message"`
+		}{}
+		json.NewDecoder(res.Body).Decode(&e)
+		return fmt.Errorf("%s %s: %s %s", method, u, res.Status, e.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// commentBody renders findings as a markdown table.
+func commentBody(findings []*finding) string {
+	b := &strings.Builder{}
+	if len(findings) == 0 {
+		fmt.Fprintf(b, "**%s**: no exposed files found.\n", Name)
+		return b.String()
+	}
+	fmt.Fprintf(b, "**%s**: %d exposed files found.\n\n", Name, len(findings))
+	b.WriteString("| Kind | Path | URL |\n|---|---|---|\n")
+	for _, f := range findings {
+		fmt.Fprintf(b, "| %s | `%s` | %s |\n", f.Kind, markdownCell(f.Path), markdownCell(f.URL))
+	}
+	return b.String()
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "`", "'", "\n", " ").Replace(s)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAnnotations(t *testing.T) {
+	b := &bytes.Buffer{}
+	writeAnnotations(b, []*finding{
+		{Kind: findingPublished, Path: "./a,b.php", URL: "https://example.com/a,b.php"},
+		{Kind: findingUnverified, Path: "./c.php", URL: "https://example.com/c.php"},
+	})
+	expect := "::error file=a%2Cb.php,title=pmr published::This file is published at https://example.com/a,b.php\n" +
+		"::warning file=c.php,title=pmr unverified::This file may be published at https://example.com/c.php, the local file can't be read to compare\n"
+	if b.String() != expect {
+		t.Errorf("expected %q to eq %q", b.String(), expect)
+	}
+}
+
+func TestSetGitHubOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	if err := setGitHubOutput("findings", "2"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	if string(b) != "findings=2\n" {
+		t.Errorf("expected %q to eq %q", string(b), "findings=2\n")
+	}
+}
+
+func TestParseGitHubPR(t *testing.T) {
+	pr, err := parseGitHubPR("pyama86/pmr#123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.repo != "pyama86/pmr" || pr.number != 123 {
+		t.Errorf("expected %+v to eq pyama86/pmr#123", pr)
+	}
+	for _, s := range []string{"pmr#1", "pyama86/pmr", "pyama86/pmr#x", "/pmr#1", "pyama86/#1", "a/b/c#1"} {
+		if _, err := parseGitHubPR(s); err == nil {
+			t.Errorf("expected %q to be an error", s)
+		}
+	}
+}
+
+func TestGitHubClient_comment(t *testing.T) {
+	comments := []*githubComment{{ID: 1, Body: "lgtm"}}
+	var auth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/o/r/issues/1/comments":
+			json.NewEncoder(w).Encode(comments)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/o/r/issues/1/comments":
+			c := &githubComment{ID: int64(len(comments) + 1)}
+			json.NewDecoder(r.Body).Decode(c)
+			comments = append(comments, c)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/o/r/issues/comments/2":
+			json.NewDecoder(r.Body).Decode(comments[1])
+			json.NewEncoder(w).Encode(comments[1])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	c := &githubClient{client: api.Client(), api: api.URL, token: "secret"}
+	pr := &githubPR{repo: "o/r", number: 1}
+	if err := c.comment(pr, commentBody([]*finding{{Kind: findingPublished, Path: "./a.php", URL: "https://example.com/a.php"}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.comment(pr, commentBody(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("expected %q to eq %q", auth, "Bearer secret")
+	}
+	if len(comments) != 2 {
+		t.Fatalf("expected the report comment to be updated, got %d comments", len(comments))
+	}
+	expect := commentMarker + "\n**pmr**: no exposed files found.\n"
+	if comments[1].Body != expect {
+		t.Errorf("expected %q to eq %q", comments[1].Body, expect)
+	}
+}
+
+func TestCommentBody(t *testing.T) {
+	body := commentBody([]*finding{{Kind: findingPublished, Path: "./a|b.php", URL: "https://example.com/a%7Cb.php"}})
+	expect := "**pmr**: 1 exposed files found.\n\n| Kind | Path | URL |\n|---|---|---|\n| published | `./a\\|b.php` | https://example.com/a%7Cb.php |\n"
+	if body != expect {
+		t.Errorf("expected %q to eq %q", body, expect)
+	}
+}
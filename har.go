@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// harRecorder collects the requests of a scan in HAR 1.2 format, for
+// browser devtools and HAR analyzers.
+type harRecorder struct {
+	mu      sync.Mutex
+	entries []*harEntry
+}
+
+type harLog struct {
+	Log struct {
+		Version string      `json:"version"`
+		Creator harCreator  `json:"creator"`
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	// Error is a custom field for requests that got no response.
+	Error string `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Headers     []harPair `json:"headers"`
+	QueryString []harPair `json:"queryString"`
+	Cookies     []harPair `json:"cookies"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int       `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Headers     []harPair  `json:"headers"`
+	Cookies     []harPair  `json:"cookies"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHARRecorder() *harRecorder {
+	return &harRecorder{}
+}
+
+// add records a request for u that started at start and took d. Either r
+// or err is set.
+func (h *harRecorder) add(u string, start time.Time, d time.Duration, r *Response, err error) {
+	ms := float64(d) / float64(time.Millisecond)
+	e := &harEntry{
+		StartedDateTime: start,
+		Time:            ms,
+		Request: harRequest{
+			Method:      http.MethodGet,
+			URL:         u,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []harPair{},
+			QueryString: []harPair{},
+			Cookies:     []harPair{},
+			HeadersSize: -1,
+		},
+		Response: harResponse{
+			Headers:     []harPair{},
+			Cookies:     []harPair{},
+			HeadersSize: -1,
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	if pu, err := url.Parse(u); err == nil {
+		for k, vs := range pu.Query() {
+			for _, v := range vs {
+				e.Request.QueryString = append(e.Request.QueryString, harPair{k, v})
+			}
+		}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		if r.Proto != "" {
+			e.Request.HTTPVersion = r.Proto
+		}
+		e.Request.Headers = harHeaders(r.RequestHeader)
+		size := len(r.Body)
+		if r.truncated() {
+			// only the head of the body was kept for the text
+			size = int(r.Size)
+		}
+		e.Response = harResponse{
+			Status:      r.StatusCode,
+			StatusText:  strings.TrimPrefix(r.Status, strconv.Itoa(r.StatusCode)+" "),
+			HTTPVersion: r.Proto,
+			Headers:     harHeaders(r.Header),
+			Cookies:     []harPair{},
+			Content: harContent{
+				Size:     size,
+				MimeType: r.Header.Get("Content-Type"),
+			},
+			RedirectURL: r.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    size,
+		}
+		if utf8.Valid(r.Body) {
+			e.Response.Content.Text = string(r.Body)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(r.Body)
+			e.Response.Content.Encoding = "base64"
+		}
+	}
+
+	h.mu.Lock()
+	h.entries = append(h.entries, e)
+	h.mu.Unlock()
+}
+
+func harHeaders(h http.Header) []harPair {
+	pairs := []harPair{}
+	for k, vs := range h {
+		for _, v := range vs {
+			pairs = append(pairs, harPair{k, v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+func (h *harRecorder) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := &harLog{}
+	l.Log.Version = "1.2"
+	l.Log.Creator = harCreator{Name: Name, Version: Version}
+	l.Log.Entries = h.entries
+	if l.Log.Entries == nil {
+		l.Log.Entries = []*harEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+func (h *harRecorder) writeFile(path string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := h.write(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
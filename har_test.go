@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHARRecorder(t *testing.T) {
+	h := newHARRecorder()
+	start := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	h.add("https://example.com/a.php?v=1", start, 1500*time.Microsecond, &Response{
+		StatusCode:    200,
+		Status:        "200 OK",
+		Proto:         "HTTP/1.1",
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          []byte("<?php"),
+		RequestHeader: http.Header{"User-Agent": {"PyamaMultiRequest/test"}},
+	}, nil)
+	h.add("https://example.com/b.bin", start, time.Millisecond, &Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Body:       []byte{0xff, 0xfe},
+	}, nil)
+	h.add("https://down.example.com/", start, time.Millisecond, nil, errors.New("connection refused"))
+
+	b := &bytes.Buffer{}
+	if err := h.write(b); err != nil {
+		t.Fatal(err)
+	}
+	l := &harLog{}
+	if err := json.Unmarshal(b.Bytes(), l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Log.Version != "1.2" || len(l.Log.Entries) != 3 {
+		t.Fatalf("unexpected log %s", b)
+	}
+
+	e := l.Log.Entries[0]
+	if e.Time != 1.5 || e.Response.StatusText != "OK" || e.Response.Content.Text != "<?php" ||
+		e.Request.QueryString[0] != (harPair{"v", "1"}) || e.Request.Headers[0] != (harPair{"User-Agent", "PyamaMultiRequest/test"}) {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := l.Log.Entries[1]; e.Response.Content.Encoding != "base64" || e.Response.Content.Text != "//4=" {
+		t.Errorf("unexpected content %+v", e.Response.Content)
+	}
+	if e := l.Log.Entries[2]; e.Error != "connection refused" || e.Response.Status != 0 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const execTimeout = time.Minute
+
+// runExec runs command through the shell for f, with {path}, {url} and
+// {kind} replaced by the quoted values of f and f as JSON on stdin.
+func runExec(command string, f *finding) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	line := strings.NewReplacer(
+		"{path}", shellQuote(f.Path),
+		"{url}", shellQuote(f.URL),
+		"{kind}", shellQuote(f.Kind),
+	).Replace(command)
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", line)
+	cmd.Stdin = bytes.NewReader(append(b, '\n'))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %s: %s: %s", line, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for sh, so paths can't inject
+// commands.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	f := &finding{Kind: findingPublished, Path: "./it's $(x).php", URL: "https://example.com/a.php"}
+	if err := runExec("printf '%s %s\\n' {path} {url} > "+out+"; cat >> "+out, f); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "./it's $(x).php https://example.com/a.php\n" +
+		`{"kind":"published","path":"./it's $(x).php","url":"https://example.com/a.php","time":"0001-01-01T00:00:00Z"}` + "\n"
+	if string(b) != expected {
+		t.Errorf("expected %q to eq %q", string(b), expected)
+	}
+
+	if err := runExec("exit 3", f); err == nil {
+		t.Errorf("expected a failing command to be an error")
+	}
+}
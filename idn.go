@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const acePrefix = "xn--"
+
+// asciiURL returns u with an internationalized host name in its punycode
+// form, mapped and validated by UTS #46, so that it can be dialed and sent
+// in Host headers as is. u is returned as is when its host is ASCII.
+func asciiURL(u string) (string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	host := pu.Hostname()
+	if isASCII(host) {
+		return u, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	port := pu.Port()
+	pu.Host = ascii
+	if port != "" {
+		pu.Host = net.JoinHostPort(ascii, port)
+	}
+	return pu.String(), nil
+}
+
+// displayURL returns u with a punycode host name in its Unicode form, as
+// the owner of the domain would write it. u is returned as is when it
+// has none or it doesn't decode.
+func displayURL(u string) string {
+	if !strings.Contains(u, acePrefix) {
+		return u
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	host, err := idna.Lookup.ToUnicode(pu.Hostname())
+	if err != nil || host == pu.Hostname() {
+		return u
+	}
+	// url.URL.String escapes non-ASCII hosts, so the host is put back
+	// into the string instead
+	return strings.Replace(u, pu.Hostname(), host, 1)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
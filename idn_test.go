@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestASCIIURL(t *testing.T) {
+	tests := []struct {
+		u        string
+		expected string
+	}{
+		{"https://日本語.jp/app/", "https://xn--wgv71a119e.jp/app/"},
+		{"https://Bücher.example:8443", "https://xn--bcher-kva.example:8443"},
+		{"https://example.com/ä", "https://example.com/ä"},
+		{"https://ＥＸＡＭＰＬＥ.日本語.jp", "https://example.xn--wgv71a119e.jp"},
+	}
+	for _, tt := range tests {
+		got, err := asciiURL(tt.u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expected {
+			t.Errorf("expected %q to eq %q", got, tt.expected)
+		}
+	}
+
+	if _, err := asciiURL("https://ä_b.example/"); err == nil {
+		t.Error("expected an error for an invalid host")
+	}
+
+	if got, expected := displayURL("https://xn--wgv71a119e.jp/index.php"), "https://日本語.jp/index.php"; got != expected {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+	if got, expected := displayURL("https://example.com/xn--a"), "https://example.com/xn--a"; got != expected {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	inputFormatPlain  = "plain"
+	inputFormatNDJSON = "ndjson"
+)
+
+// entry is a single path to check against a target.
+type entry struct {
+	Path    string            `json:"path"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Head holds the first lines of the local file when it was read from
+	// somewhere other than the local tree, e.g. an archive member or the
+	// coordinator of a distributed scan.
+	Head []string `json:"head"`
+	// Expect is the status the url has to answer with, if set.
+	Expect int `json:"expect,omitempty"`
+
+	// source names where the path was discovered when it didn't come
+	// from the local tree, e.g. "sitemap" or "robots.txt".
+	source string
+	// storage is set when URL points at an object storage bucket.
+	storage string
+}
+
+// parseEntries splits the input body into entries according to format.
+// Entries that don't specify their own base URL inherit baseURL.
+func parseEntries(body []byte, format, baseURL string) ([]*entry, error) {
+	entries := []*entry{}
+	for i, l := range strings.Split(string(body), "\n") {
+		l = strings.TrimRight(l, "\r")
+		if l == "" {
+			continue
+		}
+
+		switch format {
+		case inputFormatPlain:
+			p, expect, err := parseExpect(l)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			entries = append(entries, &entry{Path: p, URL: baseURL, Expect: expect})
+		case inputFormatNDJSON:
+			e := &entry{}
+			if err := json.Unmarshal([]byte(l), e); err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			if e.Path == "" {
+				return nil, fmt.Errorf("line %d: path is required", i+1)
+			}
+			if e.URL == "" {
+				e.URL = baseURL
+			}
+			if e.Expect != 0 && (e.Expect < 100 || e.Expect > 599) {
+				return nil, fmt.Errorf("line %d: invalid expect %d", i+1, e.Expect)
+			}
+			entries = append(entries, e)
+		default:
+			return nil, fmt.Errorf("unknown input format: %s", format)
+		}
+	}
+	return entries, nil
+}
+
+// parseExpect splits an `expect=404` annotation off the end of a plain
+// input line.
+func parseExpect(l string) (string, int, error) {
+	i := strings.LastIndex(l, " expect=")
+	if i < 0 {
+		return l, 0, nil
+	}
+	v := l[i+len(" expect="):]
+	expect, err := strconv.Atoi(v)
+	if err != nil || expect < 100 || expect > 599 {
+		return "", 0, fmt.Errorf("invalid expect %q", v)
+	}
+	return strings.TrimRight(l[:i], " "), expect, nil
+}
+
+// extFilter keeps paths by their extension. Extensions are matched
+// without the leading dot and ignoring case, and may span dots like
+// "tar.gz".
+type extFilter struct {
+	include []string
+	exclude []string
+}
+
+func newExtFilter(include, exclude string) *extFilter {
+	split := func(s string) []string {
+		exts := []string{}
+		for _, ext := range strings.Split(s, ",") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" {
+				exts = append(exts, ext)
+			}
+		}
+		return exts
+	}
+	return &extFilter{include: split(include), exclude: split(exclude)}
+}
+
+func (f *extFilter) match(path string) bool {
+	p := strings.ToLower(path)
+	has := func(exts []string) bool {
+		for _, ext := range exts {
+			if strings.HasSuffix(p, "."+ext) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(f.include) > 0 && !has(f.include) {
+		return false
+	}
+	return !has(f.exclude)
+}
+
+func (f *extFilter) filter(entries []*entry) []*entry {
+	kept := []*entry{}
+	for _, e := range entries {
+		if f.match(e.Path) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveEntries enumerates the regular files in a zip or tar(.gz)
+// archive, reading their first lines without extracting them.
+func archiveEntries(archivePath, baseURL string) ([]*entry, error) {
+	switch {
+	case strings.HasSuffix(archivePath, ".zip"):
+		return zipEntries(archivePath, baseURL)
+	case strings.HasSuffix(archivePath, ".tar.gz"), strings.HasSuffix(archivePath, ".tgz"):
+		fp, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		zr, err := gzip.NewReader(fp)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return tarEntries(zr, baseURL)
+	case strings.HasSuffix(archivePath, ".tar"):
+		fp, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		return tarEntries(fp, baseURL)
+	}
+	return nil, fmt.Errorf("unsupported archive: %s", archivePath)
+}
+
+func zipEntries(archivePath, baseURL string) ([]*entry, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	entries := []*entry{}
+	for _, f := range zr.File {
+		if !f.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry{
+			Path:   memberPath(f.Name),
+			URL:    baseURL,
+			source: "archive",
+			Head:   readHead(r),
+		})
+		r.Close()
+	}
+	return entries, nil
+}
+
+func tarEntries(r io.Reader, baseURL string) ([]*entry, error) {
+	entries := []*entry{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		entries = append(entries, &entry{
+			Path:   memberPath(hdr.Name),
+			URL:    baseURL,
+			source: "archive",
+			Head:   readHead(tr),
+		})
+	}
+	return entries, nil
+}
+
+// memberPath converts an archive member name into a relative path.
+func memberPath(name string) string {
+	return "./" + strings.TrimPrefix(path.Clean("/"+name), "/")
+}
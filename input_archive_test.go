@@ -0,0 +1,40 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveEntries_tarGz(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "build.tar.gz")
+	fp, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(fp)
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755})
+	for name, body := range map[string]string{"app/index.php": "<?php\necho 1;\n"} {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body))})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	zw.Close()
+	fp.Close()
+
+	entries, err := archiveEntries(p, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./app/index.php", URL: "https://example.com", source: "archive", Head: []string{"<?php", "echo 1;"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+type imageManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// layerChange is what a single image layer does to the filesystem below it.
+type layerChange struct {
+	files   map[string][]string
+	removed []string
+	opaque  []string
+}
+
+// imageEntries returns an entry for every regular file under root in the
+// flattened filesystem of image. image is either a tarball written by
+// `docker save` or a reference that is pulled and saved with the docker CLI.
+func imageEntries(image, root, baseURL string) ([]*entry, error) {
+	tarball := image
+	if _, err := os.Stat(image); err != nil {
+		tmp, err := saveImage(image)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp)
+		tarball = tmp
+	}
+
+	fp, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	files, err := flattenImage(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	root = path.Clean("/" + root)
+	paths := []string{}
+	for p := range files {
+		if root == "/" || strings.HasPrefix(p, root+"/") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	entries := make([]*entry, 0, len(paths))
+	for _, p := range paths {
+		entries = append(entries, &entry{
+			Path:   "./" + strings.TrimPrefix(strings.TrimPrefix(p, root), "/"),
+			URL:    baseURL,
+			source: "image",
+			Head:   files[p],
+		})
+	}
+	return entries, nil
+}
+
+func saveImage(image string) (string, error) {
+	tmp, err := ioutil.TempFile("", Name)
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	logrus.Infof("pull image %s", image)
+	for _, args := range [][]string{{"pull", image}, {"save", "-o", tmp.Name(), image}} {
+		if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+			os.Remove(tmp.Name())
+			return "", fmt.Errorf("docker %s: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return tmp.Name(), nil
+}
+
+// flattenImage reads a `docker save` tarball and applies its layers in
+// manifest order, returning the head of every regular file by absolute path.
+func flattenImage(r io.Reader) (map[string][]string, error) {
+	var manifests []imageManifest
+	layers := map[string]*layerChange{}
+	links := map[string]string{}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case hdr.Name == "manifest.json":
+			if err := json.NewDecoder(tr).Decode(&manifests); err != nil {
+				return nil, fmt.Errorf("manifest.json: %s", err)
+			}
+		case hdr.Typeflag == tar.TypeSymlink:
+			links[hdr.Name] = path.Join(path.Dir(hdr.Name), hdr.Linkname)
+		case hdr.FileInfo().Mode().IsRegular():
+			// config and index blobs are not tarballs and are ignored here
+			if lc, err := readLayer(tr); err == nil {
+				layers[hdr.Name] = lc
+			}
+		}
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("manifest.json not found in image")
+	}
+
+	files := map[string][]string{}
+	for _, name := range manifests[0].Layers {
+		if l, ok := links[name]; ok {
+			name = l
+		}
+		lc, ok := layers[name]
+		if !ok {
+			return nil, fmt.Errorf("layer %s not found in image", name)
+		}
+		for _, d := range lc.opaque {
+			removeTree(files, d, false)
+		}
+		for _, p := range lc.removed {
+			removeTree(files, p, true)
+		}
+		for p, head := range lc.files {
+			files[p] = head
+		}
+	}
+	return files, nil
+}
+
+func readLayer(r io.Reader) (*layerChange, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	lc := &layerChange{files: map[string][]string{}}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(p)
+		switch {
+		case base == whiteoutOpaque:
+			lc.opaque = append(lc.opaque, path.Clean(dir))
+		case strings.HasPrefix(base, whiteoutPrefix):
+			lc.removed = append(lc.removed, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		case hdr.FileInfo().Mode().IsRegular():
+			lc.files[p] = readHead(tr)
+		}
+	}
+	return lc, nil
+}
+
+// removeTree deletes everything below p, and p itself when self is true.
+func removeTree(files map[string][]string, p string, self bool) {
+	if self {
+		delete(files, p)
+	}
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for f := range files {
+		if strings.HasPrefix(f, prefix) {
+			delete(files, f)
+		}
+	}
+}
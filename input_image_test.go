@@ -0,0 +1,75 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testLayer(t *testing.T, compress bool, files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	if !compress {
+		return buf.Bytes()
+	}
+
+	zbuf := new(bytes.Buffer)
+	zw := gzip.NewWriter(zbuf)
+	zw.Write(buf.Bytes())
+	zw.Close()
+	return zbuf.Bytes()
+}
+
+func TestImageEntries_savedTarball(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "image.tar")
+	fp, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(fp)
+	members := []struct {
+		name string
+		body []byte
+	}{
+		{"l1/layer.tar", testLayer(t, false, map[string]string{
+			"var/www/index.php": "<?php index",
+			"var/www/old.php":   "<?php old",
+			"etc/passwd":        "root:x:0:0",
+		})},
+		{"l2/layer.tar", testLayer(t, true, map[string]string{
+			"var/www/.wh.old.php": "",
+			"var/www/new.php":     "<?php new",
+		})},
+		{"manifest.json", []byte(`[{"Config": "config.json", "Layers": ["l1/layer.tar", "l2/layer.tar"]}]`)},
+	}
+	for _, m := range members {
+		tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(m.body))})
+		tw.Write(m.body)
+	}
+	tw.Close()
+	fp.Close()
+
+	entries, err := imageEntries(p, "/var/www", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./index.php", URL: "https://example.com", source: "image", Head: []string{"<?php index"}},
+		{Path: "./new.php", URL: "https://example.com", source: "image", Head: []string{"<?php new"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
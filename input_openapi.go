@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// openAPIDocument is the part of an OpenAPI 3 or Swagger 2 document read
+// by -from-openapi. YAML is a superset of JSON, so both formats parse.
+type openAPIDocument struct {
+	Servers []*openAPIServer `yaml:"servers"`
+	// Host, BasePath and Schemes are the server of Swagger 2.
+	Host     string                            `yaml:"host"`
+	BasePath string                            `yaml:"basePath"`
+	Schemes  []string                          `yaml:"schemes"`
+	Paths    map[string]map[string]interface{} `yaml:"paths"`
+}
+
+type openAPIServer struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+// servers returns the server urls of d with their variables set to their
+// defaults.
+func (d *openAPIDocument) servers() ([]string, error) {
+	raw := []string{}
+	for _, s := range d.Servers {
+		u := s.URL
+		for name, v := range s.Variables {
+			u = strings.Replace(u, "{"+name+"}", v.Default, -1)
+		}
+		raw = append(raw, u)
+	}
+	if d.Host != "" {
+		scheme := "https"
+		if len(d.Schemes) > 0 {
+			scheme = d.Schemes[0]
+		}
+		raw = append(raw, scheme+"://"+d.Host+d.BasePath)
+	}
+
+	servers := []string{}
+	for _, r := range raw {
+		u, err := url.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		if !u.IsAbs() {
+			return nil, fmt.Errorf("server %s is relative, give the url with -url", r)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		servers = append(servers, u.String())
+	}
+	return servers, nil
+}
+
+// staticRoutes returns the routes of d that look like files: they answer
+// GET, have no path parameters and their last segment has an extension.
+func (d *openAPIDocument) staticRoutes() []string {
+	routes := []string{}
+	for route, item := range d.Paths {
+		_, get := item["get"]
+		_, ref := item["$ref"]
+		if !get && !ref {
+			continue
+		}
+		if strings.Contains(route, "{") || path.Ext(route) == "" {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// openAPIEntries returns an entry for every static route of the OpenAPI
+// document at p on each of its servers, or only on baseURL when it is set.
+func openAPIEntries(p, baseURL string) ([]*entry, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	d := &openAPIDocument{}
+	if err := yaml.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("%s: %s", p, err)
+	}
+
+	servers := []string{baseURL}
+	if baseURL == "" {
+		if servers, err = d.servers(); err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("%s: no servers, give the url with -url", p)
+		}
+	}
+	entries := []*entry{}
+	for _, s := range servers {
+		for _, r := range d.staticRoutes() {
+			entries = append(entries, &entry{Path: "./" + strings.TrimPrefix(r, "/"), URL: s, source: "openapi"})
+		}
+	}
+	return entries, nil
+}
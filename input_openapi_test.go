@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPIEntries(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "openapi.yaml")
+	spec := `openapi: 3.0.0
+servers:
+  - url: https://{host}/v1
+    variables:
+      host:
+        default: api.example.com
+paths:
+  /openapi.json:
+    get: {}
+  /static/app.js:
+    get: {}
+  /users:
+    get: {}
+  /users/{id}.json:
+    get: {}
+  /upload.php:
+    post: {}
+`
+	if err := ioutil.WriteFile(p, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := openAPIEntries(p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*entry{
+		{Path: "./openapi.json", URL: "https://api.example.com/v1/", source: "openapi"},
+		{Path: "./static/app.js", URL: "https://api.example.com/v1/", source: "openapi"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+
+	entries, err = openAPIEntries(p, "https://staging.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].URL != "https://staging.example.com" {
+		t.Errorf("expected the routes on %q, got %+v", "https://staging.example.com", entries)
+	}
+}
+
+func TestOpenAPIEntries_swagger(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "swagger.json")
+	spec := `{"swagger": "2.0", "host": "example.com", "basePath": "/api", "schemes": ["http"], "paths": {"/spec.yaml": {"get": {}}}}`
+	if err := ioutil.WriteFile(p, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := openAPIEntries(p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*entry{{Path: "./spec.yaml", URL: "http://example.com/api/", source: "openapi"}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
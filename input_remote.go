@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSitemapDepth bounds how many sitemap index levels are followed.
+const maxSitemapDepth = 3
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapDocument matches both <urlset> and <sitemapindex> roots.
+type sitemapDocument struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapEntries fetches the sitemap at sitemapURL, following sitemap
+// indexes, and returns an entry for every listed location.
+func sitemapEntries(ctx context.Context, client *http.Client, sitemapURL, baseURL string) ([]*entry, error) {
+	return walkSitemap(ctx, client, sitemapURL, baseURL, 0)
+}
+
+func walkSitemap(ctx context.Context, client *http.Client, sitemapURL, baseURL string, depth int) ([]*entry, error) {
+	body, err := fetchBody(ctx, client, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(sitemapURL, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		if body, err = ioutil.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	doc := sitemapDocument{}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", sitemapURL, err)
+	}
+
+	entries := []*entry{}
+	for _, s := range doc.Sitemaps {
+		if depth >= maxSitemapDepth {
+			logrus.Warnf("sitemap index too deep, skip %s", s.Loc)
+			continue
+		}
+		es, err := walkSitemap(ctx, client, strings.TrimSpace(s.Loc), baseURL, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	for _, l := range doc.URLs {
+		e, err := entryFromURL(l.Loc, baseURL, "sitemap")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// robotsEntries fetches the robots.txt at robotsURL and returns an entry
+// for every Disallow rule. Rules containing wildcards can't be mapped to
+// a single path and are skipped.
+func robotsEntries(ctx context.Context, client *http.Client, robotsURL, baseURL string) ([]*entry, error) {
+	body, err := fetchBody(ctx, client, robotsURL)
+	if err != nil {
+		return nil, err
+	}
+	ru, err := url.Parse(robotsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	entries := []*entry{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		l := scanner.Text()
+		if i := strings.Index(l, "#"); i >= 0 {
+			l = l[:i]
+		}
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "disallow") {
+			continue
+		}
+
+		p := strings.TrimSuffix(strings.TrimSpace(kv[1]), "$")
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if strings.Contains(p, "*") {
+			logrus.Infof("skip wildcard rule in robots.txt %s", p)
+			continue
+		}
+
+		ref, err := url.Parse(p)
+		if err != nil {
+			return nil, err
+		}
+		e, err := entryFromURL(ru.ResolveReference(ref).String(), baseURL, "robots.txt")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// entryFromURL maps a remote URL back to a path in the local tree. When
+// baseURL is empty the origin of the URL itself is used as the target.
+func entryFromURL(raw, baseURL, source string) (*entry, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+	}
+	return &entry{Path: "." + u.Path, URL: baseURL, source: source}, nil
+}
+
+func fetchBody(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request: %s %s", u, r.Status)
+	}
+	return ioutil.ReadAll(r.Body)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSitemapEntries(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/pages.xml</loc></sitemap></sitemapindex>`, ts.URL)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/about.html</loc></url><url><loc>%s/a/b.php?x=1</loc></url></urlset>`, ts.URL, ts.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	entries, err := sitemapEntries(context.Background(), ts.Client(), ts.URL+"/sitemap.xml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./about.html", URL: ts.URL, source: "sitemap"},
+		{Path: "./a/b.php", URL: ts.URL, source: "sitemap"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
+
+func TestRobotsEntries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /admin/ # secret\nDisallow: /*.bak$\nDisallow:\nAllow: /public\ndisallow: /db.sql$\n")
+	}))
+	defer ts.Close()
+
+	entries, err := robotsEntries(context.Background(), ts.Client(), ts.URL+"/robots.txt", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./admin/", URL: "https://example.com", source: "robots.txt"},
+		{Path: "./db.sql", URL: "https://example.com", source: "robots.txt"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
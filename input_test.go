@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseEntries_plain(t *testing.T) {
+	body := []byte("./a.php\n\n./b.php\r\n")
+	entries, err := parseEntries(body, inputFormatPlain, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./a.php", URL: "https://example.com"},
+		{Path: "./b.php", URL: "https://example.com"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+}
+
+func TestParseEntries_ndjson(t *testing.T) {
+	body := []byte(`{"path": "./a.php"}
+{"path": "./b.php", "url": "https://other.example.com", "headers": {"Host": "b.example.com"}}
+`)
+	entries, err := parseEntries(body, inputFormatNDJSON, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*entry{
+		{Path: "./a.php", URL: "https://example.com"},
+		{Path: "./b.php", URL: "https://other.example.com", Headers: map[string]string{"Host": "b.example.com"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v to eq %+v", entries, expected)
+	}
+
+	if _, err := parseEntries([]byte(`{"url": "https://example.com"}`), inputFormatNDJSON, ""); err == nil {
+		t.Error("expected error for entry without path")
+	}
+}
+
+func TestExtFilter(t *testing.T) {
+	entries := []*entry{
+		{Path: "./index.PHP"}, {Path: "./.env"}, {Path: "./logo.png"}, {Path: "./backup.tar.gz"}, {Path: "./img"},
+	}
+
+	tests := []struct {
+		include, exclude string
+		expected         []string
+	}{
+		{"", "", []string{"./index.PHP", "./.env", "./logo.png", "./backup.tar.gz", "./img"}},
+		{"php, .env,tar.gz", "", []string{"./index.PHP", "./.env", "./backup.tar.gz"}},
+		{"", "png,jpg,woff2", []string{"./index.PHP", "./.env", "./backup.tar.gz", "./img"}},
+		{"php,png", "png", []string{"./index.PHP"}},
+	}
+	for _, tt := range tests {
+		paths := []string{}
+		for _, e := range newExtFilter(tt.include, tt.exclude).filter(entries) {
+			paths = append(paths, e.Path)
+		}
+		if !reflect.DeepEqual(paths, tt.expected) {
+			t.Errorf("expected %v to eq %v", paths, tt.expected)
+		}
+	}
+}
+
+func TestParseEntries_expect(t *testing.T) {
+	es, err := parseEntries([]byte("./index.html expect=200\n./.env  expect=404\n./a b.php\n"), inputFormatPlain, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf("%q %d %q %d %q %d", es[0].Path, es[0].Expect, es[1].Path, es[1].Expect, es[2].Path, es[2].Expect)
+	expect := `"./index.html" 200 "./.env" 404 "./a b.php" 0`
+	if got != expect {
+		t.Errorf("expected %s to eq %s", got, expect)
+	}
+
+	if _, err := parseEntries([]byte("./index.html expect=ok\n"), inputFormatPlain, "https://example.com"); err == nil {
+		t.Error("expected an invalid expect to be an error")
+	}
+	es, err = parseEntries([]byte(`{"path": "./.env", "expect": 404}`), inputFormatNDJSON, "https://example.com")
+	if err != nil || es[0].Expect != 404 {
+		t.Errorf("expected expect to be read from ndjson, got %+v %v", es, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"path"
+	"regexp"
+)
+
+// listingPattern matches the auto-index pages of Apache, nginx, lighttpd,
+// IIS and Python's http.server.
+var listingPattern = regexp.MustCompile(`(?i)<title>\s*(index of|directory listing for) /|\[to parent directory\]`)
+
+// listingDirs returns an entry for every parent directory of the paths of
+// entries, including the top one, once per url. Paths of templates and
+// buckets have no directories to list.
+func listingDirs(entries []*entry) []*entry {
+	seen := map[string]bool{}
+	dirs := []*entry{}
+	for _, e := range entries {
+		if e.storage != "" || isTemplate(e.URL) {
+			continue
+		}
+		p := slashPath(e.Path)
+		for {
+			p = path.Dir(p)
+			d := p + "/"
+			if p == "." {
+				d = "./"
+			} else if p == "/" {
+				d = "/"
+			}
+			if !seen[e.URL+" "+d] {
+				seen[e.URL+" "+d] = true
+				dirs = append(dirs, &entry{Path: d, URL: e.URL, Headers: e.Headers})
+			}
+			if p == "." || p == "/" {
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// checkListings requests the parent directories of entries and reports
+// the ones that answer with a directory listing.
+func (s *scanner) checkListings(ctx context.Context, entries []*entry) error {
+	return s.probeAll(ctx, listingDirs(entries), findingListing, "This directory is listed %s at %s", func(e *entry, r *Response) bool {
+		return listingPattern.Match(r.Body)
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListingDirs(t *testing.T) {
+	dirs := listingDirs([]*entry{
+		{Path: "./a/b/c.php", URL: "https://example.com"},
+		{Path: "./a/d.php", URL: "https://example.com"},
+		{Path: "/var/www/e.php", URL: "https://example.com"},
+		{Path: "./f.php", URL: "https://example.com/{path}"},
+	})
+	got := []string{}
+	for _, d := range dirs {
+		got = append(got, d.Path)
+	}
+	expect := fmt.Sprint([]string{"a/b/", "a/", "./", "/var/www/", "/var/", "/"})
+	if fmt.Sprint(got) != expect {
+		t.Errorf("expected %q to eq %q", fmt.Sprint(got), expect)
+	}
+}
+
+func TestScanner_checkListings(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/uploads/":
+			fmt.Fprint(w, "<html><head><title>Index of /uploads/</title></head><body><h1>Index of /uploads/</h1></body></html>")
+		case "/":
+			fmt.Fprint(w, "<html><head><title>Top</title></head></html>")
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer target.Close()
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	err := s.checkListings(ctx, []*entry{
+		{Path: "./uploads/a.png", URL: target.URL},
+		{Path: "./lib/b.php", URL: target.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].Kind != findingListing || s.findings[0].URL != target.URL+"/uploads/" {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+	if s.stats.requests != 3 {
+		t.Errorf("expected %d requests, got %d", 3, s.stats.requests)
+	}
+}
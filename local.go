@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// localPolicy decides which files of the local tree are read. The zero
+// value reads everything a path resolves to.
+type localPolicy struct {
+	// skipSymlinks skips symbolic links instead of reading their target.
+	skipSymlinks bool
+	// skipSpecial skips FIFOs, devices, sockets and dangling symbolic
+	// links, which are an error otherwise.
+	skipSpecial bool
+	// maxSize is the size in bytes above which files are skipped, or
+	// compared by hash with hashLarge. 0 means no limit.
+	maxSize   int64
+	hashLarge bool
+}
+
+// check returns why path should not be read, or an empty string when it
+// should. Missing files are left to the caller.
+func (p localPolicy) check(path string) (string, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", nil
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if p.skipSymlinks {
+			return "symbolic link", nil
+		}
+		if fi, err = os.Stat(path); err != nil {
+			if p.skipSpecial {
+				return "dangling symbolic link", nil
+			}
+			return "", nil
+		}
+	}
+	if fi.Mode().IsRegular() && p.maxSize > 0 && fi.Size() > p.maxSize && !p.hashLarge {
+		return fmt.Sprintf("larger than %d bytes", p.maxSize), nil
+	}
+	if fi.Mode().IsRegular() || fi.IsDir() {
+		return "", nil
+	}
+	// opening a FIFO would block until someone writes to it
+	if p.skipSpecial {
+		return "special file", nil
+	}
+	return "", fmt.Errorf("%s is not a regular file (%s)", path, fi.Mode().Type())
+}
+
+// localPath returns the local file of e. Relative paths are read from
+// root when it is set.
+func (s *scanner) localPath(e *entry) string {
+	if s.root == "" || filepath.IsAbs(e.Path) {
+		return e.Path
+	}
+	return filepath.Join(s.root, e.Path)
+}
+
+// skipLocal reports whether e is skipped because of its local file.
+func (s *scanner) skipLocal(e *entry) (bool, error) {
+	if e.Head != nil {
+		return false, nil
+	}
+	reason, err := s.local.check(s.localPath(e))
+	if err != nil {
+		s.record(&Result{Path: e.Path, Verdict: verdictError, Error: err.Error()})
+		if s.skipError(errorLocal, err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if reason != "" {
+		logrus.Infof("skip %s: %s", e.Path, reason)
+		s.record(&Result{Path: e.Path, Verdict: verdictSkipped, Error: reason})
+		return true, nil
+	}
+	return false, nil
+}
+
+// large reports whether path is over maxSize and compared by hash.
+func (p localPolicy) large(path string) bool {
+	if !p.hashLarge || p.maxSize <= 0 {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() > p.maxSize
+}
+
+// sameHash reports whether the sha256 of the file at path is sum.
+func sameHash(path string, sum []byte) (bool, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return false, err
+	}
+	return bytes.Equal(h.Sum(nil), sum), nil
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	n := strings.TrimSuffix(strings.ToUpper(s), "B")
+	switch {
+	case strings.HasSuffix(n, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(n, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(n, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		n = n[:len(n)-1]
+	}
+	v, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return v * mult, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalPolicy_check(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.php")
+	if err := os.WriteFile(file, []byte("<?php"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.php")
+	dangling := filepath.Join(dir, "dangling.php")
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "nope"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "s.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+
+	tests := []struct {
+		policy localPolicy
+		path   string
+		skip   bool
+		err    bool
+	}{
+		{localPolicy{}, file, false, false},
+		{localPolicy{}, link, false, false},
+		{localPolicy{skipSymlinks: true}, link, true, false},
+		{localPolicy{}, dangling, false, false},
+		{localPolicy{skipSpecial: true}, dangling, true, false},
+		{localPolicy{}, sock, false, true},
+		{localPolicy{skipSpecial: true}, sock, true, false},
+		{localPolicy{}, dir, false, false},
+	}
+	for _, tt := range tests {
+		reason, err := tt.policy.check(tt.path)
+		if (reason != "") != tt.skip || (err != nil) != tt.err {
+			t.Errorf("unexpected result %q, %v for %s with %+v", reason, err, tt.path, tt.policy)
+		}
+	}
+}
+
+func TestLocalPolicy_maxSize(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.php")
+	large := filepath.Join(dir, "dump.sql")
+	if err := os.WriteFile(small, []byte("<?php"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(large, []byte("INSERT INTO users VALUES (1);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := localPolicy{maxSize: 10}
+	if reason, _ := p.check(small); reason != "" {
+		t.Errorf("expected %s not to be skipped, got %q", small, reason)
+	}
+	if reason, _ := p.check(large); reason != "larger than 10 bytes" {
+		t.Errorf("expected %q to eq %q", reason, "larger than 10 bytes")
+	}
+
+	p.hashLarge = true
+	if reason, _ := p.check(large); reason != "" || !p.large(large) || p.large(small) {
+		t.Errorf("expected only %s to be compared by hash", large)
+	}
+	sum := sha256.Sum256([]byte("INSERT INTO users VALUES (1);"))
+	if same, err := sameHash(large, sum[:]); err != nil || !same {
+		t.Errorf("expected hashes to match, got %v %v", same, err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"512": 512, "10K": 10 << 10, "100M": 100 << 20, "1gb": 1 << 30}
+	for s, want := range tests {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("expected %q to eq %d, got %d %v", s, want, got, err)
+		}
+	}
+	if _, err := parseSize("big"); err == nil {
+		t.Errorf("expected %q to be an error", "big")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// digestTop is how many findings are listed in a digest mail.
+const digestTop = 10
+
+// smtpConfig is the mail server of the config file.
+type smtpConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// sendDigest mails a summary of findings of a scan of target to the
+// recipients. report names where the full results can be found, if
+// anywhere.
+func sendDigest(c *smtpConfig, to []string, target string, findings []*finding, report string) error {
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	return smtp.SendMail(addr, auth, c.From, to, digestMail(c.From, to, target, findings, report, time.Now()))
+}
+
+func digestMail(from string, to []string, target string, findings []*finding, report string, now time.Time) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", from)
+	fmt.Fprintf(b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(b, "Subject: [%s] %d findings on %s\r\n", Name, len(findings), target)
+	fmt.Fprintf(b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+
+	fmt.Fprintf(b, "%s found %d exposed files on %s.\r\n\r\n", Name, len(findings), target)
+	for i, f := range findings {
+		if i == digestTop {
+			fmt.Fprintf(b, "... and %d more\r\n", len(findings)-digestTop)
+			break
+		}
+		fmt.Fprintf(b, "%s %s %s\r\n", f.Kind, f.Path, f.URL)
+	}
+	if report != "" {
+		fmt.Fprintf(b, "\r\nFull report: %s\r\n", report)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestMail(t *testing.T) {
+	findings := []*finding{}
+	for i := 0; i < digestTop+2; i++ {
+		findings = append(findings, &finding{Kind: findingPublished, Path: fmt.Sprintf("./%d.php", i), URL: fmt.Sprintf("https://example.com/%d.php", i)})
+	}
+	m := string(digestMail("pmr@example.com", []string{"a@example.com", "b@example.com"}, "https://example.com", findings, "/tmp/results.json", time.Now()))
+
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [pmr] 12 findings on https://example.com\r\n",
+		"published ./9.php https://example.com/9.php\r\n",
+		"... and 2 more\r\n",
+		"Full report: /tmp/results.json\r\n",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("expected %q to contain %q", m, want)
+		}
+	}
+	if strings.Contains(m, "./10.php") {
+		t.Errorf("expected %q not to list more than %d findings", m, digestTop)
+	}
+}
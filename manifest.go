@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// manifestEntries reads a manifest in the format of sha256sum, like the
+// SHA256SUMS of a release, and returns an entry for every listed file
+// along with the checksums by path.
+func manifestEntries(p, baseURL string) ([]*entry, map[string][]byte, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := []*entry{}
+	sums := map[string][]byte{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("%s:%d: expected a checksum and a file name", p, n)
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != 32 {
+			return nil, nil, fmt.Errorf("%s:%d: invalid sha256 checksum %s", p, n, fields[0])
+		}
+		// sha256sum separates the name by " *" in binary mode
+		name := strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		path := "./" + strings.TrimPrefix(strings.TrimPrefix(name, "./"), "/")
+		if _, ok := sums[path]; !ok {
+			entries = append(entries, &entry{Path: path, URL: baseURL})
+		}
+		sums[path] = sum
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, sums, nil
+}
+
+// verifyManifest requests every path of entries and reports the ones that
+// aren't served, or are served with content not matching their checksum
+// in sums. It returns the number of reported paths, and the error of ctx
+// when it is done before every path was verified.
+func (s *scanner) verifyManifest(ctx context.Context, entries []*entry, sums map[string][]byte) (int64, error) {
+	var failed int64
+	eg := errgroup.Group{}
+	for _, e := range entries {
+		if !s.gate.acquire(ctx) {
+			break
+		}
+		e := e
+		eg.Go(func() error {
+			defer s.gate.release()
+			a, err := s.serve(ctx, e)
+			if err != nil || a == nil {
+				return err
+			}
+			var msg string
+			switch {
+			case a.status != http.StatusOK:
+				msg = fmt.Sprintf("%s of the manifest answered %d at %s", e.Path, a.status, a.url)
+			case !bytes.Equal(a.sum, sums[e.Path]):
+				msg = fmt.Sprintf("%s doesn't match the manifest at %s, got sha256 %x, expected %x", e.Path, a.url, a.sum, sums[e.Path])
+			default:
+				return nil
+			}
+			atomic.AddInt64(&failed, 1)
+			f := &finding{Kind: findingMismatch, Path: e.Path, URL: a.url, Time: time.Now()}
+			if s.report(f) {
+				findingLog(f).Warn(msg)
+			}
+			return nil
+		})
+	}
+	err := eg.Wait()
+	if err == nil {
+		// paths not started, or whose requests were canceled and skipped,
+		// weren't verified
+		err = ctx.Err()
+	}
+	return atomic.LoadInt64(&failed), err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestScanner_verifyManifest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pmr_linux_amd64.zip":
+			fmt.Fprint(w, "release")
+		case "/pmr_darwin_amd64.zip":
+			fmt.Fprint(w, "tampered")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256([]byte("release"))
+	p := filepath.Join(t.TempDir(), "SHA256SUMS")
+	manifest := fmt.Sprintf("%x  pmr_linux_amd64.zip\n%x *pmr_darwin_amd64.zip\n%x  pmr_windows_amd64.zip\n", sum, sum, sum)
+	if err := ioutil.WriteFile(p, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, sums, err := manifestEntries(p, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].Path != "./pmr_darwin_amd64.zip" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	failed, err := s.verifyManifest(ctx, entries, sums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 2 {
+		t.Errorf("expected %d to eq %d", failed, 2)
+	}
+	got := []string{}
+	for _, f := range s.findings {
+		got = append(got, f.Kind+" "+f.Path)
+	}
+	sort.Strings(got)
+	expected := []string{"mismatch ./pmr_darwin_amd64.zip", "mismatch ./pmr_windows_amd64.zip"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+}
+
+func TestRun_manifestDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	p := filepath.Join(t.TempDir(), "SHA256SUMS")
+	sum := sha256.Sum256([]byte("release"))
+	if err := ioutil.WriteFile(p, []byte(fmt.Sprintf("%x  a.zip\n%x  b.zip\n%x  c.zip\n", sum, sum, sum)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{outStream: outStream, errStream: errStream}
+	args := []string{"./pmr", "-url", ts.URL + "/", "-manifest", p, "-c", "1", "-deadline", "100ms", "-skip-network-errors"}
+	if status := cli.Run(args); status != ExitCodeError {
+		t.Errorf("expected an unfinished verification to fail, got %d: %s", status, errStream.String())
+	}
+}
+
+func TestManifestEntries_invalid(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := ioutil.WriteFile(p, []byte("abc  file.zip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := manifestEntries(p, "https://example.com"); err == nil {
+		t.Error("expected an error for an invalid checksum")
+	}
+}
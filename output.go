@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fieldFinding marks log entries that report an exposed file.
+const fieldFinding = "finding"
+
+// findingLog returns the log entry that reports f.
+func findingLog(f *finding) *logrus.Entry {
+	fields := logrus.Fields{fieldFinding: true, "severity": f.Severity}
+	if f.Message != "" {
+		fields["message"] = f.Message
+	}
+	return logrus.WithFields(fields)
+}
+
+const (
+	colorRed    = "31;1"
+	colorYellow = "33"
+	colorCyan   = "36"
+	colorGray   = "90"
+)
+
+// consoleFormatter renders log entries as aligned, severity colored lines
+// for interactive terminals.
+type consoleFormatter struct {
+	color bool
+}
+
+func (f *consoleFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	label, color := "INFO", colorCyan
+	switch {
+	case e.Data[fieldFinding] == true:
+		label, color = "FOUND", colorRed
+	case e.Level <= logrus.ErrorLevel:
+		label, color = "ERROR", colorRed
+	case e.Level == logrus.WarnLevel:
+		label, color = "WARN", colorYellow
+	case e.Level >= logrus.DebugLevel:
+		label, color = "DEBUG", colorGray
+	}
+
+	b := &bytes.Buffer{}
+	if f.color {
+		fmt.Fprintf(b, "\x1b[%sm%-5s\x1b[0m %s", color, label, e.Message)
+	} else {
+		fmt.Fprintf(b, "%-5s %s", label, e.Message)
+	}
+
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		if k != fieldFinding {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s=%v", k, e.Data[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	fp, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// setupLogger sends logs to w. Terminals get the aligned console format,
+// anything else keeps the logfmt style of logrus so logs stay parseable.
+func setupLogger(w io.Writer, noColor bool) {
+	logrus.SetOutput(w)
+	if isTerminal(w) {
+		logrus.SetFormatter(&consoleFormatter{color: !noColor})
+	} else {
+		logrus.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConsoleFormatter(t *testing.T) {
+	e := logrus.WithField(fieldFinding, true)
+	e.Level = logrus.WarnLevel
+	e.Message = "This file is published ./a.php"
+
+	b, err := (&consoleFormatter{}).Format(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "FOUND This file is published ./a.php\n"
+	if string(b) != expected {
+		t.Errorf("expected %q to eq %q", b, expected)
+	}
+
+	b, err = (&consoleFormatter{color: true}).Format(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "\x1b[31;1mFOUND\x1b[0m This file is published ./a.php\n"
+	if string(b) != expected {
+		t.Errorf("expected %q to eq %q", b, expected)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	pathpkg "path"
+	"strings"
+)
+
+var templateFields = []string{"{path}", "{dir}", "{base}", "{host}"}
+
+// urlJoin resolves the file name path against base. Every segment is
+// escaped, so names with spaces, '#', '?' or non-ASCII characters request
+// the file of that name. Windows paths are converted by slashPath.
+func urlJoin(base, path string) (string, error) {
+	path = slashPath(path)
+	return resolveURL(base, &url.URL{Path: path, RawPath: escapePath(path)})
+}
+
+// urlJoinRaw resolves path against base as a URL reference, so it may
+// carry its own query and escapes.
+func urlJoinRaw(base, path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return resolveURL(base, u)
+}
+
+func resolveURL(base string, ref *url.URL) (string, error) {
+	pb, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	return pb.ResolveReference(ref).String(), nil
+}
+
+func escapePath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// slashPath converts a Windows path to a URL path: backslashes become
+// slashes, and drive letters and UNC hosts are dropped so that
+// C:\src\index.php is requested as /src/index.php.
+func slashPath(path string) string {
+	if strings.HasPrefix(path, `\\`) {
+		// \\server\share\... would be taken as a host
+		path = strings.TrimLeft(path, `\`)
+		if i := strings.Index(path, `\`); i >= 0 {
+			path = path[i:]
+		} else {
+			path = "/"
+		}
+	}
+	path = strings.Replace(path, `\`, "/", -1)
+	if len(path) >= 2 && path[1] == ':' && (len(path) == 2 || path[2] == '/') &&
+		('a' <= path[0] && path[0] <= 'z' || 'A' <= path[0] && path[0] <= 'Z') {
+		path = "/" + strings.TrimPrefix(path[2:], "/")
+	}
+	return path
+}
+
+// caseVariants returns the lower and upper case variants of path that
+// differ from it. Case insensitive origins behind case sensitive caches
+// often serve a file under any of them.
+func caseVariants(path string) []string {
+	variants := []string{}
+	for _, v := range []string{strings.ToLower(path), strings.ToUpper(path)} {
+		if v != path && (len(variants) == 0 || variants[0] != v) {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// queryRule rewrites the query string of requested URLs.
+type queryRule struct {
+	// strip drops the query of the base url or a raw path.
+	strip bool
+	// append is added to the query, e.g. to get past CDN caches.
+	append string
+}
+
+func (q queryRule) apply(u string) (string, error) {
+	if !q.strip && q.append == "" {
+		return u, nil
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if q.strip {
+		pu.RawQuery = ""
+	}
+	if q.append != "" {
+		if pu.RawQuery != "" {
+			pu.RawQuery += "&"
+		}
+		pu.RawQuery += strings.TrimPrefix(q.append, "?")
+	}
+	return pu.String(), nil
+}
+
+// isTemplate reports whether u has placeholders for expandTemplate.
+func isTemplate(u string) bool {
+	for _, f := range templateFields {
+		if strings.Contains(u, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateHost returns the host of the url of tmpl, or "" when it has a
+// placeholder.
+func templateHost(tmpl string) string {
+	i := strings.Index(tmpl, "://")
+	if i < 0 {
+		return ""
+	}
+	host := tmpl[i+3:]
+	if j := strings.IndexAny(host, "/?#"); j >= 0 {
+		host = host[:j]
+	}
+	if j := strings.LastIndex(host, "@"); j >= 0 {
+		host = host[j+1:]
+	}
+	if strings.ContainsAny(host, "{}") {
+		return ""
+	}
+	return host
+}
+
+// expandTemplate fills the placeholders of tmpl for e: {path} is the path
+// without its leading ./ or /, {dir} and {base} are its directory and file
+// name, and {host} is the Host header of e, or the host of the template
+// itself, e.g. for https://example.com/{host}/{path}. Path parts are
+// escaped unless raw is set.
+func expandTemplate(tmpl string, e *entry, raw bool) (string, error) {
+	p := strings.TrimLeft(strings.TrimPrefix(slashPath(e.Path), "./"), "/")
+	dir, base := pathpkg.Split(p)
+	dir = strings.TrimSuffix(dir, "/")
+	esc := escapePath
+	if raw {
+		esc = func(s string) string { return s }
+	}
+
+	host := ""
+	for k, v := range e.Headers {
+		if strings.EqualFold(k, "Host") {
+			host = v
+		}
+	}
+	if host == "" && strings.Contains(tmpl, "{host}") {
+		if host = templateHost(tmpl); host == "" {
+			return "", fmt.Errorf("%s: {host} needs a Host header for %s", tmpl, e.Path)
+		}
+	}
+	return strings.NewReplacer(
+		"{path}", esc(p),
+		"{dir}", esc(dir),
+		"{base}", esc(base),
+		"{host}", host,
+	).Replace(tmpl), nil
+}
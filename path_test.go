@@ -0,0 +1,142 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURLJoin(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		raw      string
+	}{
+		{"./index.php", "https://example.com/app/index.php", "https://example.com/app/index.php"},
+		{"./my file.php", "https://example.com/app/my%20file.php", "https://example.com/app/my%20file.php"},
+		{"./a#b.php", "https://example.com/app/a%23b.php", "https://example.com/app/a#b.php"},
+		{"./a?b.php", "https://example.com/app/a%3Fb.php", "https://example.com/app/a?b.php"},
+		{"./日本語.php", "https://example.com/app/%E6%97%A5%E6%9C%AC%E8%AA%9E.php", "https://example.com/app/%E6%97%A5%E6%9C%AC%E8%AA%9E.php"},
+		{"./100%.php", "https://example.com/app/100%25.php", ""},
+		{"/abs/x.php", "https://example.com/abs/x.php", "https://example.com/abs/x.php"},
+	}
+	for _, tt := range tests {
+		u, err := urlJoin("https://example.com/app/", tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tt.expected {
+			t.Errorf("expected %q to eq %q", u, tt.expected)
+		}
+
+		u, err = urlJoinRaw("https://example.com/app/", tt.path)
+		if tt.raw == "" {
+			if err == nil {
+				t.Errorf("expected %q to be an error with -raw-path", tt.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tt.raw {
+			t.Errorf("expected %q to eq %q", u, tt.raw)
+		}
+	}
+}
+
+func TestSlashPath(t *testing.T) {
+	tests := map[string]string{
+		`.\src\index.php`:       "./src/index.php",
+		`C:\src\index.php`:      "/src/index.php",
+		`c:/src/index.php`:      "/src/index.php",
+		`C:`:                    "/",
+		`\\server\share\a.php`:  "/share/a.php",
+		`./a:b.php`:             "./a:b.php",
+		`x:y.php`:               "x:y.php",
+		"/var/www/index.php":    "/var/www/index.php",
+		"//var/www/index.php":   "//var/www/index.php",
+		`src\my file\index.php`: "src/my file/index.php",
+	}
+	for p, expected := range tests {
+		if got := slashPath(p); got != expected {
+			t.Errorf("expected %q to eq %q", got, expected)
+		}
+	}
+
+	u, err := urlJoin("https://example.com/", `C:\src\index.php`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "https://example.com/src/index.php" {
+		t.Errorf("expected %q to eq %q", u, "https://example.com/src/index.php")
+	}
+}
+
+func TestCaseVariants(t *testing.T) {
+	tests := map[string][]string{
+		"./Index.php": {"./index.php", "./INDEX.PHP"},
+		"./index.php": {"./INDEX.PHP"},
+		"./1.txt":     {"./1.TXT"},
+		"./1":         {},
+	}
+	for p, expected := range tests {
+		if got := caseVariants(p); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %q to eq %q", got, expected)
+		}
+	}
+}
+
+func TestQueryRule(t *testing.T) {
+	tests := []struct {
+		rule     queryRule
+		u        string
+		expected string
+	}{
+		{queryRule{}, "https://example.com/a.php?x=1", "https://example.com/a.php?x=1"},
+		{queryRule{append: "v=123"}, "https://example.com/a.php", "https://example.com/a.php?v=123"},
+		{queryRule{append: "?v=123"}, "https://example.com/a.php?x=1", "https://example.com/a.php?x=1&v=123"},
+		{queryRule{strip: true}, "https://example.com/a.php?x=1", "https://example.com/a.php"},
+		{queryRule{strip: true, append: "v=123"}, "https://example.com/a.php?x=1", "https://example.com/a.php?v=123"},
+	}
+	for _, tt := range tests {
+		u, err := tt.rule.apply(tt.u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tt.expected {
+			t.Errorf("expected %q to eq %q", u, tt.expected)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		e        *entry
+		expected string
+	}{
+		{"https://cdn.example.com/static/{path}", &entry{Path: "./css/a b.css"}, "https://cdn.example.com/static/css/a%20b.css"},
+		{"https://example.com/{dir}/v2/{base}", &entry{Path: "./js/app.js"}, "https://example.com/js/v2/app.js"},
+		{"https://{host}/{path}", &entry{Path: "/index.php", Headers: map[string]string{"host": "a.example.com"}}, "https://a.example.com/index.php"},
+		{"https://example.com/{path}", &entry{Path: `.\src\index.php`}, "https://example.com/src/index.php"},
+		{"https://cdn.example.com:8443/{host}/{path}", &entry{Path: "./a.css"}, "https://cdn.example.com:8443/cdn.example.com:8443/a.css"},
+		{"https://cdn.example.com/{host}/{path}", &entry{Path: "./a.css", Headers: map[string]string{"Host": "a.example.com"}}, "https://cdn.example.com/a.example.com/a.css"},
+	}
+	for _, tt := range tests {
+		tt.e.URL = tt.tmpl
+		if !isTemplate(tt.tmpl) {
+			t.Errorf("expected %q to be a template", tt.tmpl)
+		}
+		u, err := expandTemplate(tt.tmpl, tt.e, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tt.expected {
+			t.Errorf("expected %q to eq %q", u, tt.expected)
+		}
+	}
+
+	if _, err := expandTemplate("https://{host}/{path}", &entry{Path: "./index.php"}, false); err == nil {
+		t.Errorf("expected a missing host to be an error")
+	}
+}
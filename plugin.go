@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Matcher is a content check run on every response answering 200, next to
+// the comparison with the local file. It returns a message when the
+// response is a finding, or "" when it isn't.
+type Matcher interface {
+	Match(ctx context.Context, e *entry, u string, r *Response) (string, error)
+}
+
+// Fetchers and matchers registered by the init functions of files added
+// to the build, so that proprietary protocols and content checks don't
+// need changes to the rest of pmr. A registered fetcher replaces the
+// built-in one of its scheme.
+var (
+	fetcherPlugins = map[string]Fetcher{}
+	matcherPlugins = map[string]Matcher{}
+)
+
+func registerFetcher(scheme string, f Fetcher) {
+	fetcherPlugins[strings.ToLower(scheme)] = f
+}
+
+// registerMatcher adds m to every scan. Its findings are of kind name.
+func registerMatcher(name string, m Matcher) {
+	matcherPlugins[name] = m
+}
+
+// parsePlugins parses comma separated name=command pairs.
+func parsePlugins(s string) (map[string]string, error) {
+	plugins := map[string]string{}
+	if s == "" {
+		return plugins, nil
+	}
+	for _, p := range strings.Split(s, ",") {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid plugin %q, expected name=command", p)
+		}
+		plugins[kv[0]] = kv[1]
+	}
+	return plugins, nil
+}
+
+// pluginMessage is written as JSON to the stdin of exec plugins. Fetcher
+// plugins answer with one too, carrying the status, header and body.
+type pluginMessage struct {
+	Path   string      `json:"path,omitempty"`
+	URL    string      `json:"url,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is base64 in JSON.
+	Body []byte `json:"body,omitempty"`
+}
+
+// runPlugin runs command through the shell with in as JSON on stdin and
+// returns its stdout.
+func runPlugin(ctx context.Context, command string, in *pluginMessage) ([]byte, error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(append(b, '\n'))
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %s: %s", command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// execFetcher fetches urls by running an external command. The request
+// headers of the entry are passed in header.
+type execFetcher struct {
+	command string
+}
+
+func (f *execFetcher) Fetch(ctx context.Context, e *entry, u string) (*Response, error) {
+	in := &pluginMessage{Path: e.Path, URL: u, Header: http.Header{}}
+	for k, v := range e.Headers {
+		in.Header.Set(k, v)
+	}
+	out, err := runPlugin(ctx, f.command, in)
+	if err != nil {
+		return nil, err
+	}
+	pr := &pluginMessage{}
+	if err := json.Unmarshal(out, pr); err != nil {
+		return nil, fmt.Errorf("plugin %s: %s", f.command, err)
+	}
+	if pr.Status < 100 || pr.Status > 599 {
+		return nil, fmt.Errorf("plugin %s: invalid status %d", f.command, pr.Status)
+	}
+	body, size, err := readBody(ctx, bytes.NewReader(pr.Body))
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		StatusCode:    pr.Status,
+		Status:        fmt.Sprintf("%d %s", pr.Status, http.StatusText(pr.Status)),
+		Header:        pr.Header,
+		Body:          body,
+		Size:          size,
+		RequestHeader: in.Header,
+	}, nil
+}
+
+// execMatcher checks responses by running an external command. Its
+// trimmed stdout is the message of the finding, if any.
+type execMatcher struct {
+	command string
+}
+
+func (m *execMatcher) Match(ctx context.Context, e *entry, u string, r *Response) (string, error) {
+	out, err := runPlugin(ctx, m.command, &pluginMessage{Path: e.Path, URL: u, Status: r.StatusCode, Header: r.Header, Body: r.Body})
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// match runs every matcher on a response answering 200 and reports their
+// findings.
+func (s *scanner) match(ctx context.Context, e *entry, u string, r *Response) {
+	names := make([]string, 0, len(s.matchers))
+	for name := range s.matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg, err := s.matchers[name].Match(ctx, e, u, r)
+		if err != nil {
+			logrus.Errorf("matcher %s: %s", name, err)
+			continue
+		}
+		if msg == "" {
+			continue
+		}
+		f := &finding{Kind: name, Path: e.Path, URL: u, Time: time.Now()}
+		if s.report(f) {
+			findingLog(f).Warnf("%s %s at %s", msg, e.Path, u)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type bodyContains string
+
+func (b bodyContains) Match(ctx context.Context, e *entry, u string, r *Response) (string, error) {
+	if strings.Contains(string(r.Body), string(b)) {
+		return "This file contains " + string(b), nil
+	}
+	return "", nil
+}
+
+func TestParsePlugins(t *testing.T) {
+	p, err := parsePlugins("x=fetch-x --flag=1,y=fetch-y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p["x"] != "fetch-x --flag=1" || p["y"] != "fetch-y" {
+		t.Errorf("unexpected plugins %v", p)
+	}
+	if _, err := parsePlugins("x"); err == nil {
+		t.Error("expected a plugin without a command to be an error")
+	}
+}
+
+func TestScanner_plugins(t *testing.T) {
+	root := t.TempDir()
+	ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("hello\n"), 0644)
+
+	fs := newFetchers(http.DefaultClient, 3, &tls.Config{}, nil)
+	// "aGVsbG8K" is "hello\n"
+	fs["x"] = &execFetcher{command: `cat > /dev/null; echo '{"status": 200, "header": {"X-Test": ["1"]}, "body": "aGVsbG8K"}'`}
+	s := &scanner{fetchers: fs, root: root, matchers: map[string]Matcher{
+		"greeting": bodyContains("hello"),
+		"internal": &execMatcher{command: `grep -q '"X-Test":\["1"\]' && echo 'This file has a test header'`},
+		"secret":   bodyContains("password"),
+	}}
+	if err := s.request(context.Background(), &entry{Path: "./a.txt", URL: "x://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{}
+	for _, f := range s.findings {
+		kinds = append(kinds, f.Kind)
+	}
+	expect := "greeting internal published"
+	if strings.Join(kinds, " ") != expect {
+		t.Errorf("expected %q to eq %q", strings.Join(kinds, " "), expect)
+	}
+
+	fs["x"] = &execFetcher{command: "echo broken; exit 1"}
+	if err := s.request(context.Background(), &entry{Path: "./a.txt", URL: "x://example.org"}); err == nil || !strings.Contains(err.Error(), "plugin echo broken; exit 1: exit status 1") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// portTarget is a port of -ports with the scheme it is requested with.
+type portTarget struct {
+	scheme string
+	port   string
+}
+
+// parsePorts parses comma separated ports, each optionally prefixed with
+// its scheme like https:9443. Without one, 443 and 8443 are requested
+// with https and other ports with http.
+func parsePorts(s string) ([]portTarget, error) {
+	ports := []portTarget{}
+	seen := map[portTarget]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		t := portTarget{scheme: "http", port: p}
+		if i := strings.Index(p, ":"); i >= 0 {
+			t.scheme, t.port = strings.ToLower(p[:i]), p[i+1:]
+		} else if p == "443" || p == "8443" {
+			t.scheme = "https"
+		}
+		if n, err := strconv.Atoi(t.port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		if t.scheme != "http" && t.scheme != "https" {
+			return nil, fmt.Errorf("invalid scheme of port %q, expected http or https", p)
+		}
+		if !seen[t] {
+			seen[t] = true
+			ports = append(ports, t)
+		}
+	}
+	return ports, nil
+}
+
+// portURLs returns u on each of ports, in their order. Urls that aren't
+// http(s) are returned as they are.
+func portURLs(u string, ports []portTarget) ([]string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 || (pu.Scheme != "http" && pu.Scheme != "https") {
+		return []string{u}, nil
+	}
+	urls := []string{}
+	for _, p := range ports {
+		v := *pu
+		v.Scheme, v.Host = p.scheme, net.JoinHostPort(pu.Hostname(), p.port)
+		if (p.scheme == "http" && p.port == "80") || (p.scheme == "https" && p.port == "443") {
+			v.Host = pu.Hostname()
+			if strings.Contains(v.Host, ":") {
+				v.Host = "[" + v.Host + "]"
+			}
+		}
+		urls = append(urls, v.String())
+	}
+	return urls, nil
+}
+
+// unreachable reports whether the listener of u was found closed or
+// speaking another protocol before.
+func (s *scanner) unreachable(u string) bool {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	_, ok := s.closedPorts.Load(pu.Scheme + "://" + pu.Host)
+	return ok
+}
+
+// portFailed decides what a failed check of u on a port of -ports means.
+// Connection and TLS errors, and timeouts of the dial to a filtered port,
+// mark the listener unreachable, so it isn't requested again, and don't
+// stop the scan. Other errors of the check stop it like they do without
+// -ports, as check only returns the ones the skip flags don't cover. It
+// returns whether the scan should stop.
+func (s *scanner) portFailed(u string, res *Result, err error) bool {
+	var opErr *net.OpError
+	switch {
+	case res.ErrorKind == errorKindConnect, res.ErrorKind == errorKindTLS,
+		res.ErrorKind == errorKindTimeout && errors.As(err, &opErr) && opErr.Op == "dial":
+		if pu, err := url.Parse(u); err == nil {
+			if _, loaded := s.closedPorts.LoadOrStore(pu.Scheme+"://"+pu.Host, true); !loaded {
+				logrus.Infof("%s://%s is unreachable, skipping it: %s", pu.Scheme, pu.Host, res.Error)
+			}
+		}
+		return false
+	}
+	return true
+}
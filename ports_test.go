@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPortURLs(t *testing.T) {
+	ports, err := parsePorts("80,443,8080,8443,https:9443,80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls, err := portURLs("https://example.com/app/index.php?v=1", ports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"http://example.com/app/index.php?v=1",
+		"https://example.com/app/index.php?v=1",
+		"http://example.com:8080/app/index.php?v=1",
+		"https://example.com:8443/app/index.php?v=1",
+		"https://example.com:9443/app/index.php?v=1",
+	}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %q to eq %q", urls, expected)
+	}
+
+	for _, s := range []string{"0", "http", "ftp:21", "70000"} {
+		if _, err := parsePorts(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestScanner_ports(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	serve := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body == "" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, body)
+		}))
+	}
+	site, staging, admin := serve(""), serve("<?php secret"), serve("<?php secret")
+	defer site.Close()
+	defer staging.Close()
+	defer admin.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	port := func(ts *httptest.Server) string {
+		u, _ := url.Parse(ts.URL)
+		return u.Port()
+	}
+	ports, err := parsePorts(fmt.Sprintf("%s,%s,%d,%s", port(site), port(staging), closed, port(admin)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), root: root, ports: ports}
+	for _, p := range []string{"./secret.php", "./secret.php"} {
+		if err := s.request(context.Background(), &entry{Path: p, URL: site.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := []string{}
+	for _, f := range s.findings {
+		got = append(got, f.URL)
+	}
+	sort.Strings(got)
+	expected := []string{staging.URL + "/secret.php", staging.URL + "/secret.php", admin.URL + "/secret.php", admin.URL + "/secret.php"}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q to eq %q", got, expected)
+	}
+	// the closed port is only requested once
+	if s.stats.errors != 1 {
+		t.Errorf("expected %d to eq %d", s.stats.errors, 1)
+	}
+}
+
+func TestScanner_portsErrors(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	site := httptest.NewServer(http.NotFoundHandler())
+	defer site.Close()
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer reset.Close()
+	ports, err := parsePorts(strings.TrimPrefix(reset.URL, "http://127.0.0.1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, skip := range []bool{false, true} {
+		s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), root: root, ports: ports, skip: skipPolicy{network: skip}}
+		err := s.request(context.Background(), &entry{Path: "./secret.php", URL: site.URL})
+		if (err != nil) == skip {
+			t.Errorf("skip %v: unexpected error %v", skip, err)
+		}
+		if s.stats.errors != 1 || s.stats.errorKinds[errorKindReset] != 1 {
+			t.Errorf("skip %v: expected the reset to be counted, got %d %v", skip, s.stats.errors, s.stats.errorKinds)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startProfiling serves net/http/pprof on listen and starts writing a CPU
+// profile to cpu, for the options that are set. The returned function
+// stops the CPU profile and writes a heap profile to mem.
+func startProfiling(listen, cpu, mem string) (func(), error) {
+	if listen != "" {
+		l, err := net.Listen("tcp", listen)
+		if err != nil {
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		logrus.Infof("pprof listening on http://%s/debug/pprof/", l.Addr())
+		go func() {
+			if err := http.Serve(l, mux); err != nil {
+				logrus.Warn(err)
+			}
+		}()
+	}
+
+	var cpuFile *os.File
+	if cpu != "" {
+		fp, err := os.Create(cpu)
+		if err != nil {
+			return nil, err
+		}
+		if err := runtimepprof.StartCPUProfile(fp); err != nil {
+			fp.Close()
+			return nil, err
+		}
+		cpuFile = fp
+	}
+
+	return func() {
+		if cpuFile != nil {
+			runtimepprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if mem != "" {
+			if err := writeHeapProfile(mem); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// collect garbage first so the profile shows live memory
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
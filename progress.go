@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 200 * time.Millisecond
+)
+
+// progress draws a status line at the bottom of a terminal. It wraps the
+// log output so that log lines are printed above the bar instead of
+// tearing it.
+type progress struct {
+	w     io.Writer
+	total int
+	stats *stats
+	start time.Time
+	done  int64
+
+	mu   sync.Mutex
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newProgress(w io.Writer, total int, st *stats) *progress {
+	return &progress{
+		w:     w,
+		total: total,
+		stats: st,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+	}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.w, "\r\x1b[K")
+	n, err := p.w.Write(b)
+	fmt.Fprint(p.w, p.line(time.Now()))
+	return n, err
+}
+
+func (p *progress) increment() {
+	atomic.AddInt64(&p.done, 1)
+}
+
+func (p *progress) run() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.redraw()
+			case <-p.stop:
+				p.redraw()
+				fmt.Fprintln(p.w)
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) finish() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *progress) redraw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.w, "\r\x1b[K"+p.line(time.Now()))
+}
+
+func (p *progress) line(now time.Time) string {
+	done := atomic.LoadInt64(&p.done)
+	elapsed := now.Sub(p.start)
+
+	ratio := 1.0
+	if p.total > 0 {
+		ratio = float64(done) / float64(p.total)
+	}
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressWidth-filled)
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(atomic.LoadInt64(&p.stats.requests)) / elapsed.Seconds()
+	}
+	eta := "-"
+	if done > 0 {
+		eta = (time.Duration(float64(elapsed) / float64(done) * float64(int64(p.total)-done))).Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("[%s] %d/%d %3.0f%% %.1f req/s errors %d findings %d ETA %s",
+		bar, done, p.total, ratio*100, rate,
+		atomic.LoadInt64(&p.stats.errors), atomic.LoadInt64(&p.stats.findings), eta)
+}
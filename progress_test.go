@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgress_line(t *testing.T) {
+	st := &stats{requests: 50, errors: 1, findings: 2}
+	p := newProgress(nil, 100, st)
+	p.done = 25
+
+	line := p.line(p.start.Add(10 * time.Second))
+	expected := "[#######.......................] 25/100  25% 5.0 req/s errors 1 findings 2 ETA 30s"
+	if line != expected {
+		t.Errorf("expected %q to eq %q", line, expected)
+	}
+}
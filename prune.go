@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pruneInterval is how often `serve -retain` deletes old scans.
+const pruneInterval = time.Hour
+
+// runPrune deletes old scans from a database of `scan -db` or `serve -db`.
+func (cli *CLI) runPrune(args []string) int {
+	var (
+		dbPath string
+		retain string
+	)
+	flags := flag.NewFlagSet(Name+" prune", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.StringVar(&dbPath, "db", "", "database of scan -db or serve -db to prune")
+	flags.StringVar(&retain, "retain", "", "keep scans finished within this long or since this time, e.g. 90d, 2024-03-01")
+	flags.Usage = func() {
+		fmt.Fprintf(cli.errStream, "Usage: %s prune -db results.sqlite -retain 90d\n", Name)
+		flags.PrintDefaults()
+	}
+	if err := cli.parse(flags, args[1:]); err != nil {
+		return ExitCodeError
+	}
+	if dbPath == "" || retain == "" {
+		flags.Usage()
+		return ExitCodeError
+	}
+
+	before, err := parseSince(retain, time.Now())
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	db, err := openResultDB(dbPath)
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	defer db.Close()
+	scans, findings, err := db.prune(before)
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	fmt.Fprintf(cli.outStream, "deleted %d scans and %d findings finished before %s\n", scans, findings, before.Format(time.RFC3339))
+	return ExitCodeOK
+}
+
+// runRetention deletes the scans older than retain from the database and
+// from memory, now and then every pruneInterval.
+func (sv *server) runRetention(ctx context.Context, retain string) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		before, err := parseSince(retain, time.Now())
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		sv.mu.Lock()
+		for id, job := range sv.jobs {
+			if job.FinishedAt != nil && job.FinishedAt.Before(before) {
+				delete(sv.jobs, id)
+			}
+		}
+		sv.mu.Unlock()
+		if sv.db != nil {
+			scans, findings, err := sv.db.prune(before)
+			if err != nil {
+				logrus.Errorf("prune: %s", err)
+			} else if scans > 0 {
+				logrus.Infof("pruned %d scans and %d findings finished before %s", scans, findings, before.Format(time.RFC3339))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
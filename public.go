@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// publicList holds the paths of files that are meant to be served, such
+// as robots.txt, and are never reported. A pattern without a slash matches
+// the base name of a path, like in .gitignore.
+type publicList struct {
+	patterns []string
+}
+
+func readPublicList(p string) (*publicList, error) {
+	fp, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	l := &publicList{}
+	sc := bufio.NewScanner(fp)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = listPath(line)
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", p, n, err)
+		}
+		l.patterns = append(l.patterns, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// match reports whether p is on the list. A nil list matches nothing.
+func (l *publicList) match(p string) bool {
+	if l == nil {
+		return false
+	}
+	p = listPath(p)
+	for _, pattern := range l.patterns {
+		target := p
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listPath makes ./a, /a and a the same path.
+func listPath(p string) string {
+	return strings.TrimLeft(strings.TrimPrefix(p, "./"), "/")
+}
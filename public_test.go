@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublicList(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "public.txt")
+	ioutil.WriteFile(p, []byte("# served on purpose\nrobots.txt\n\n./static/*.css\n/.well-known/*\n"), 0644)
+	l, err := readPublicList(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		expect bool
+	}{
+		{"./robots.txt", true},
+		{"./blog/robots.txt", true},
+		{"./robots.txt.bak", false},
+		{"./static/a.css", true},
+		{"./static/sub/a.css", false},
+		{"./other/static/a.css", false},
+		{"./.well-known/security.txt", true},
+		{"./.env", false},
+	}
+	for _, tt := range tests {
+		if got := l.match(tt.path); got != tt.expect {
+			t.Errorf("expected %q to eq %v, got %v", tt.path, tt.expect, got)
+		}
+	}
+
+	ioutil.WriteFile(p, []byte("[a\n"), 0644)
+	if _, err := readPublicList(p); err == nil {
+		t.Error("expected a bad pattern to be an error")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// replayed is the outcome of requesting a saved finding again.
+type replayed struct {
+	f      *finding
+	status int
+	err    error
+	// exposed is whether the check of the kind of f still holds, and
+	// unknown that it can't be run again, e.g. without the local file.
+	exposed bool
+	unknown bool
+}
+
+func (r *replayed) state() string {
+	switch {
+	case r.err != nil:
+		return "error"
+	case r.unknown:
+		return "unknown"
+	case r.exposed:
+		return "exposed"
+	default:
+		return "fixed"
+	}
+}
+
+// replayChecks holds what the checks of findings need besides the
+// response. Kinds whose check needs something missing are unknown.
+type replayChecks struct {
+	root  string
+	rules severityRules
+	sums  map[string][]byte
+}
+
+// matcher returns the matcher the body of the url of f is streamed
+// through, or nil when its check doesn't need one or can't be run.
+func (c *replayChecks) matcher(f *finding) *bodyMatcher {
+	switch f.Kind {
+	case findingPublished, findingUnverified:
+		p := f.Path
+		if c.root != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(c.root, p)
+		}
+		lines, err := getFileHead(p)
+		if err != nil {
+			logrus.Infof("%s can't be compared again: %s", f.Path, err)
+			return nil
+		}
+		return newLineMatcher(lines)
+	case findingMismatch:
+		if _, ok := c.sums[f.Path]; ok {
+			return newHashMatcher()
+		}
+	}
+	return nil
+}
+
+// check re-applies the check that reported f to r, the response of its
+// url, and m, the matcher r was streamed through. unknown is set when
+// the check can't be run.
+func (c *replayChecks) check(f *finding, r *Response, m *bodyMatcher) (exposed, unknown bool) {
+	switch f.Kind {
+	case findingMismatch:
+		if m == nil {
+			return false, true
+		}
+		// the manifest reports paths that aren't served too
+		return r.StatusCode != http.StatusOK || !bytes.Equal(m.sum(), c.sums[f.Path]), false
+	case findingDivergent:
+		// only the compared targets together tell
+		return false, true
+	}
+	if r.StatusCode != http.StatusOK {
+		return false, false
+	}
+	switch f.Kind {
+	case findingPublished, findingUnverified:
+		if m != nil {
+			return m.matched(), false
+		}
+	case findingUnexpected:
+		return true, false
+	case findingSourceMap:
+		return isSourceMap(r.Body), false
+	case findingListing:
+		return listingPattern.Match(r.Body), false
+	case findingSource:
+		source, _, _ := checkContentType(f.Path, r.Header)
+		return source, false
+	case findingVCS:
+		for _, v := range vcsFiles {
+			if strings.HasSuffix(slashPath(f.Path), v.path) {
+				return v.match(r.Body), false
+			}
+		}
+	case findingHeader:
+		if c.rules != nil {
+			return len(c.rules.headerRules(f.Path, r.Header)) > 0, false
+		}
+	}
+	return false, true
+}
+
+// runReplay requests the findings saved by `scan -output` or `scan -db`
+// again and reports which of them are still served, to verify a fix
+// without scanning everything again.
+func (cli *CLI) runReplay(args []string) int {
+	var (
+		dbPath      string
+		only        string
+		root        string
+		rulesPath   string
+		manifest    string
+		output      string
+		timeout     int
+		concurrency int
+	)
+	flags := flag.NewFlagSet(Name+" replay", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.Usage = func() {
+		fmt.Fprintf(cli.errStream, "Usage: %s replay [-only published] results.json...\n       %s replay -db results.sqlite [-only published]\n", Name, Name)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&dbPath, "db", "", "read findings from this database of scan -db")
+	flags.StringVar(&only, "only", "", "comma separated kinds of findings to replay, e.g. published,vcs")
+	flags.StringVar(&output, "output", "", "write the findings that are still exposed to this file")
+	flags.StringVar(&root, "root", "", "compare published findings with the local files under this directory")
+	flags.StringVar(&rulesPath, "rules", "", "check header findings again with the header rules of this YAML file")
+	flags.StringVar(&manifest, "manifest", "", "check mismatch findings again with this sha256sum manifest")
+	flags.IntVar(&timeout, "timeout", 3, "request timeout sec")
+	flags.IntVar(&timeout, "t", 3, "request timeout sec(Short)")
+	flags.IntVar(&concurrency, "concurrency", 5, "request concurrency")
+	flags.IntVar(&concurrency, "c", 5, "request concurrency(Short)")
+	// flags may follow the files too, as in `replay results.json -only vcs`
+	paths := []string{}
+	for rest := args[1:]; ; rest = flags.Args()[1:] {
+		if err := cli.parse(flags, rest); err != nil {
+			return ExitCodeError
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+	}
+	if (len(paths) == 0) == (dbPath == "") {
+		flags.Usage()
+		return ExitCodeError
+	}
+
+	findings := []*finding{}
+	if dbPath != "" {
+		db, err := openResultDB(dbPath)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		defer db.Close()
+		if findings, err = db.findingsSince(time.Time{}, ""); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+	for _, p := range paths {
+		fs, err := readFindings(p)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		findings = append(findings, fs...)
+	}
+	findings = replayable(findings, only)
+
+	checks := &replayChecks{root: root}
+	if rulesPath != "" {
+		rules, err := readSeverityRules(rulesPath)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		checks.rules = rules
+	}
+	if manifest != "" {
+		_, sums, err := manifestEntries(manifest, "")
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		checks.sums = sums
+	}
+
+	client := newHTTPClient(timeout, nil, protoAuto, nil, transportTimeouts{})
+	results := replay(context.Background(), newFetchers(client, timeout, nil, nil), checks, findings, concurrency)
+
+	exposed, unknown := []*finding{}, 0
+	w := tabwriter.NewWriter(cli.outStream, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tKIND\tPATH\tURL\tSTATUS")
+	for _, r := range results {
+		status := fmt.Sprint(r.status)
+		if r.err != nil {
+			status = r.err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.state(), r.f.Kind, r.f.Path, r.f.URL, status)
+		switch r.state() {
+		case "exposed":
+			f := *r.f
+			f.Time = time.Now()
+			exposed = append(exposed, &f)
+		case "unknown":
+			unknown++
+		}
+	}
+	w.Flush()
+	fmt.Fprintf(cli.outStream, "%d of %d findings are still exposed\n", len(exposed), len(results))
+	if unknown > 0 {
+		fmt.Fprintf(cli.outStream, "%d findings can't be checked again, see -root, -rules and -manifest\n", unknown)
+	}
+
+	if output != "" {
+		if err := writeFindingsFile(output, exposed); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+	if len(exposed) > 0 {
+		return ExitCodeError
+	}
+	return ExitCodeOK
+}
+
+// replayable returns the findings of the kinds in only, or of every kind
+// when it is empty, once per url.
+func replayable(findings []*finding, only string) []*finding {
+	kinds := map[string]bool{}
+	for _, k := range strings.Split(only, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			kinds[k] = true
+		}
+	}
+	seen := map[string]bool{}
+	fs := []*finding{}
+	for _, f := range findings {
+		if len(kinds) > 0 && !kinds[f.Kind] {
+			continue
+		}
+		if seen[f.key()] {
+			continue
+		}
+		seen[f.key()] = true
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// replay requests the url of every finding again, and re-applies the
+// check of its kind with checks.
+func replay(ctx context.Context, fs fetchers, checks *replayChecks, findings []*finding, concurrency int) []*replayed {
+	results := make([]*replayed, len(findings))
+	g := newGate(ctx, concurrency)
+	wg := sync.WaitGroup{}
+	for i, f := range findings {
+		if !g.acquire(ctx) {
+			break
+		}
+		wg.Add(1)
+		go func(i int, f *finding) {
+			defer wg.Done()
+			defer g.release()
+			r := &replayed{f: f}
+			results[i] = r
+			fetcher, err := fs.forURL(f.URL)
+			if err != nil {
+				r.err = err
+				return
+			}
+			m := checks.matcher(f)
+			res, err := fetcher.Fetch(withMatcher(ctx, m), &entry{Path: f.Path, URL: f.URL}, f.URL)
+			if err != nil {
+				r.err = err
+				return
+			}
+			r.status = res.StatusCode
+			r.exposed, r.unknown = checks.check(f, res, m)
+		}(i, f)
+	}
+	wg.Wait()
+	return results
+}
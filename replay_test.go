@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_replay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.env" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ".env"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	in, out := filepath.Join(dir, "results.json"), filepath.Join(dir, "exposed.json")
+	now := time.Now()
+	err := writeFindingsFile(in, []*finding{
+		{Kind: findingPublished, Path: "./.env", URL: ts.URL + "/.env", Time: now},
+		{Kind: findingPublished, Path: "./.env", URL: ts.URL + "/.env", Time: now},
+		{Kind: findingPublished, Path: "./backup.sql", URL: ts.URL + "/backup.sql", Time: now},
+		{Kind: findingVCS, Path: "./.git/HEAD", URL: ts.URL + "/.git/HEAD", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{outStream: outStream, errStream: errStream}
+	status := cli.Run([]string{"./pmr", "replay", in, "-only", "published", "-root", dir, "-output", out})
+	if status != ExitCodeError {
+		t.Errorf("expected %d to eq %d", status, ExitCodeError)
+	}
+	expected := "1 of 2 findings are still exposed"
+	if !strings.Contains(outStream.String(), expected) {
+		t.Errorf("expected %q to contain %q", outStream.String(), expected)
+	}
+
+	exposed, err := readFindings(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exposed) != 1 || exposed[0].Path != "./.env" {
+		t.Errorf("expected only ./.env to be exposed, got %+v", exposed)
+	}
+}
+
+func TestRun_replayChecks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a soft 404 page answering 200 for any path
+		fmt.Fprint(w, "<html>not found</html>")
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "db.sql"), []byte("CREATE TABLE users;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := filepath.Join(dir, "results.json")
+	now := time.Now()
+	err := writeFindingsFile(in, []*finding{
+		{Kind: findingPublished, Path: "./db.sql", URL: ts.URL + "/db.sql", Time: now},
+		{Kind: findingPublished, Path: "./missing.sql", URL: ts.URL + "/missing.sql", Time: now},
+		{Kind: findingVCS, Path: "./.git/HEAD", URL: ts.URL + "/.git/HEAD", Time: now},
+		{Kind: findingSourceMap, Path: "./app.js.map", URL: ts.URL + "/app.js.map", Time: now},
+		{Kind: findingListing, Path: "./", URL: ts.URL + "/", Time: now},
+		{Kind: findingHeader, Path: "./a.php", URL: ts.URL + "/a.php", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outStream, errStream := new(bytes.Buffer), new(bytes.Buffer)
+	cli := &CLI{outStream: outStream, errStream: errStream}
+	if status := cli.Run([]string{"./pmr", "replay", in, "-root", dir}); status != ExitCodeOK {
+		t.Errorf("expected %d to eq %d: %s", status, ExitCodeOK, outStream.String())
+	}
+	for _, expected := range []string{"0 of 6 findings are still exposed", "2 findings can't be checked again"} {
+		if !strings.Contains(outStream.String(), expected) {
+			t.Errorf("expected %q to contain %q", outStream.String(), expected)
+		}
+	}
+}
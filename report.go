@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runReport prints the findings saved by `scan -output` or `scan -db`.
+func (cli *CLI) runReport(args []string) int {
+	var (
+		dbPath string
+		since  string
+		target string
+		prName string
+		token  string
+		api    string
+	)
+
+	flags := flag.NewFlagSet(Name+" report", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.Usage = func() {
+		fmt.Fprintf(cli.errStream, "Usage: %s report [-since 7d] [-github-pr owner/repo#123] results.json...\n       %s report -db results.sqlite [-since 7d] [-url url]\n", Name, Name)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&dbPath, "db", "", "read findings from this database of scan -db")
+	flags.StringVar(&since, "since", "", "only findings since this long ago or time, e.g. 7d, 12h, 2024-03-01")
+	flags.StringVar(&target, "url", "", "only findings of scans of this url(with -db)")
+	flags.StringVar(&prName, "github-pr", "", "post the findings as a comment on this pull request, e.g. owner/repo#123")
+	flags.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub token for -github-pr(default $GITHUB_TOKEN)")
+	flags.StringVar(&api, "github-api", githubAPI, "GitHub API url, e.g. https://github.example.com/api/v3")
+	if err := cli.parse(flags, args[1:]); err != nil {
+		return ExitCodeError
+	}
+	if (flags.NArg() == 0) == (dbPath == "") {
+		flags.Usage()
+		return ExitCodeError
+	}
+
+	var pr *githubPR
+	if prName != "" {
+		var err error
+		if pr, err = parseGitHubPR(prName); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		if token == "" {
+			logrus.Error("github-pr needs -token or $GITHUB_TOKEN")
+			return ExitCodeError
+		}
+	}
+
+	var from time.Time
+	if since != "" {
+		var err error
+		if from, err = parseSince(since, time.Now()); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+
+	findings := []*finding{}
+	if dbPath != "" {
+		db, err := openResultDB(dbPath)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		defer db.Close()
+		if findings, err = db.findingsSince(from, target); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+	for _, p := range flags.Args() {
+		fs, err := readFindings(p)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		for _, f := range fs {
+			if !f.Time.Before(from) {
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(cli.outStream, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tKIND\tPATH\tURL\tTIME")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Kind, f.Path, f.URL, f.Time.Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+	fmt.Fprintf(cli.outStream, "%d findings\n", len(findings))
+
+	if pr != nil {
+		gh := &githubClient{client: &http.Client{Timeout: 30 * time.Second}, api: strings.TrimRight(api, "/"), token: token}
+		if err := gh.comment(pr, commentBody(findings)); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+	}
+	return ExitCodeOK
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// verdict is the outcome of checking a path at a url.
+type verdict string
+
+const (
+	verdictPublished    verdict = "published"
+	verdictNotPublished verdict = "not-published"
+	verdictBlocked      verdict = "blocked"
+	verdictError        verdict = "error"
+	verdictSkipped      verdict = "skipped"
+)
+
+// Result is the outcome of every check of a scan, whether or not it found
+// something. Unlike findings, which are the exposures kept across scans,
+// results describe the scan itself.
+type Result struct {
+	Path    string  `json:"path"`
+	URL     string  `json:"url,omitempty"`
+	Status  int     `json:"status,omitempty"`
+	Verdict verdict `json:"verdict"`
+	// Error is why the path was skipped or couldn't be checked.
+	Error string `json:"error,omitempty"`
+	// ErrorKind is the cause of Error, e.g. dns, timeout or tls.
+	ErrorKind errorKind `json:"error_kind,omitempty"`
+	Evidence  string    `json:"evidence,omitempty"`
+	// Expect is the status the input expected, and Unmet is set when the
+	// url didn't answer with it.
+	Expect int  `json:"expect,omitempty"`
+	Unmet  bool `json:"unmet,omitempty"`
+	// Headers are the response headers of -capture-headers.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Duration is how long the request took, in nanoseconds.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// record hands res to the result writer of the scan, if any.
+func (s *scanner) record(res *Result) {
+	res.URL = displayURL(res.URL)
+	if res.ErrorKind != "" {
+		s.stats.addErrorKind(res.ErrorKind)
+	}
+	if s.results != nil {
+		s.results.write(res)
+	}
+}
+
+// expect checks res against the status e is expected to answer with.
+// Only the url of e itself is held to it, not its case variants or the
+// plain http retry of -both-schemes.
+func (s *scanner) expect(e *entry, res *Result) {
+	if e.Expect == 0 {
+		return
+	}
+	if u, err := s.resolve(e); err != nil || u != res.URL {
+		return
+	}
+	res.Expect = e.Expect
+	if res.Status == e.Expect {
+		return
+	}
+	res.Unmet = true
+	atomic.AddInt64(&s.stats.unmet, 1)
+	logrus.Errorf("%s: expected %d, got %s", res.URL, e.Expect, statusOrError(res))
+}
+
+func statusOrError(res *Result) string {
+	if res.Status == 0 {
+		return res.Error
+	}
+	return strconv.Itoa(res.Status)
+}
+
+// fail marks res as failed by err.
+func (res *Result) fail(err error) {
+	res.Verdict, res.Error, res.ErrorKind = verdictError, err.Error(), errorKindOf(err)
+}
+
+// resultWriter prints results as NDJSON as they come.
+type resultWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (rw *resultWriter) write(res *Result) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.w.Write(append(b, '\n'))
+}
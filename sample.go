@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sampleEntries returns a random subset of entries in their input order.
+// spec is a percentage like "1%" or a number of entries like "100". At
+// least one entry is kept.
+func sampleEntries(entries []*entry, spec string, rnd *rand.Rand) ([]*entry, error) {
+	var n int
+	if p := strings.TrimSuffix(spec, "%"); p != spec {
+		pct, err := strconv.ParseFloat(p, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid sample %s, expected a percentage like 1%% or a number of paths", spec)
+		}
+		n = int(float64(len(entries)) * pct / 100)
+	} else {
+		var err error
+		if n, err = strconv.Atoi(spec); err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid sample %s, expected a percentage like 1%% or a number of paths", spec)
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n >= len(entries) {
+		return entries, nil
+	}
+	picked := rnd.Perm(len(entries))[:n]
+	sort.Ints(picked)
+	sampled := make([]*entry, 0, n)
+	for _, i := range picked {
+		sampled = append(sampled, entries[i])
+	}
+	return sampled, nil
+}
+
+// confirmer asks whether to go on once a scan has reported its first
+// findings, so that a scan of the wrong target isn't run at full speed to
+// the end. The scan is paused while the question is open.
+type confirmer struct {
+	after  int64
+	in     io.Reader
+	out    io.Writer
+	cancel func()
+}
+
+// ask pauses g, asks on the terminal whether to continue the scan after
+// f and cancels it unless the answer is yes.
+func (c *confirmer) ask(g *gate, f *finding, findings int64) {
+	g.setPaused(true)
+	fmt.Fprintf(c.out, "%s finding at %s, %d findings so far. Continue the scan? [y/N] ", f.Kind, f.URL, findings)
+	answer, _ := bufio.NewReader(c.in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		g.setPaused(false)
+	default:
+		fmt.Fprintln(c.out, "stopping the scan")
+		c.cancel()
+		g.setPaused(false)
+	}
+}
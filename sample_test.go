@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSampleEntries(t *testing.T) {
+	entries := []*entry{}
+	for i := 0; i < 200; i++ {
+		entries = append(entries, &entry{Path: fmt.Sprintf("./%03d.php", i)})
+	}
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		spec     string
+		expected int
+	}{
+		{"1%", 2},
+		{"0.1%", 1},
+		{"50", 50},
+		{"1000", 200},
+	}
+	for _, tt := range tests {
+		sampled, err := sampleEntries(entries, tt.spec, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sampled) != tt.expected {
+			t.Errorf("%s: expected %d to eq %d", tt.spec, len(sampled), tt.expected)
+		}
+		for i := 1; i < len(sampled); i++ {
+			if sampled[i-1].Path >= sampled[i].Path {
+				t.Errorf("%s: expected the input order, got %s before %s", tt.spec, sampled[i-1].Path, sampled[i].Path)
+			}
+		}
+	}
+	for _, spec := range []string{"0%", "101%", "x", "-1"} {
+		if _, err := sampleEntries(entries, spec, rnd); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestConfirmer(t *testing.T) {
+	for _, tt := range []struct {
+		answer   string
+		canceled bool
+	}{
+		{"y\n", false},
+		{"\n", true},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := &bytes.Buffer{}
+		s := &scanner{gate: newGate(ctx, 1)}
+		s.confirm = &confirmer{after: 2, in: strings.NewReader(tt.answer), out: out, cancel: cancel}
+		for i := 0; i < 3; i++ {
+			s.report(&finding{Kind: findingPublished, Path: fmt.Sprintf("./%d.php", i)})
+		}
+		if got := ctx.Err() != nil; got != tt.canceled {
+			t.Errorf("%q: expected canceled %v, got %v", tt.answer, tt.canceled, got)
+		}
+		if n := strings.Count(out.String(), "Continue the scan?"); n != 1 {
+			t.Errorf("%q: expected one question, got %q", tt.answer, out.String())
+		}
+		if _, _, paused := s.gate.state(); paused {
+			t.Errorf("%q: expected the gate not to stay paused", tt.answer)
+		}
+		cancel()
+	}
+}
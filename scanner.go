@@ -0,0 +1,723 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	initScanTokenSize int = 1024 * 4
+	MaxScanTokenSize  int = 1024 * 64
+)
+
+// stats counts scan outcomes. Fields are updated atomically.
+type stats struct {
+	requests int64
+	// errors is the sum of the error classes below
+	errors        int64
+	networkErrors int64
+	dnsErrors     int64
+	localErrors   int64
+	findings      int64
+	// blocked counts responses that were a WAF block page.
+	blocked int64
+	// unmet counts urls that didn't answer with their expected status.
+	unmet int64
+
+	mu sync.Mutex
+	// errorKinds counts the results failing by each kind of error.
+	errorKinds map[errorKind]int64
+}
+
+func (st *stats) addErrorKind(k errorKind) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.errorKinds == nil {
+		st.errorKinds = map[errorKind]int64{}
+	}
+	st.errorKinds[k]++
+}
+
+// errorKindSummary lists the counts of errorKinds, most frequent first,
+// e.g. "timeout 12, reset 3".
+func (st *stats) errorKindSummary() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	kinds := make([]errorKind, 0, len(st.errorKinds))
+	for k := range st.errorKinds {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if st.errorKinds[kinds[i]] != st.errorKinds[kinds[j]] {
+			return st.errorKinds[kinds[i]] > st.errorKinds[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	parts := make([]string, 0, len(kinds))
+	for _, k := range kinds {
+		parts = append(parts, fmt.Sprintf("%s %d", k, st.errorKinds[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (st *stats) summary() string {
+	summary := fmt.Sprintf("%d requests, %d findings, %d errors (network %d, dns %d, local %d), %d blocked",
+		atomic.LoadInt64(&st.requests), atomic.LoadInt64(&st.findings), atomic.LoadInt64(&st.errors),
+		atomic.LoadInt64(&st.networkErrors), atomic.LoadInt64(&st.dnsErrors), atomic.LoadInt64(&st.localErrors),
+		atomic.LoadInt64(&st.blocked))
+	if kinds := st.errorKindSummary(); kinds != "" {
+		summary += ", failed results by cause: " + kinds
+	}
+	return summary
+}
+
+// scanner checks entries against their targets.
+type scanner struct {
+	fetchers fetchers
+	skip     skipPolicy
+	// probeUnreadable reports paths served with 200 whose local file
+	// can't be read.
+	probeUnreadable bool
+	local           localPolicy
+	// root is the directory relative paths are read from instead of the
+	// working directory.
+	root string
+	// rawPath takes paths as URL references instead of file names.
+	rawPath bool
+	// caseVariants also requests lower and upper case variants of paths.
+	caseVariants bool
+	query        queryRule
+	// evidenceDir is where the request and response of every published
+	// finding is saved when set.
+	evidenceDir string
+	// generic downgrades findings served with error or shell pages when
+	// set.
+	generic *genericPages
+	// budget is closed when the time budget of the scan runs out, which
+	// stops starting the entries left. A nil budget never runs out.
+	// remaining holds the entries left, and those a canceled scan didn't
+	// finish.
+	budget    <-chan struct{}
+	remaining []*entry
+	// ports are the ports of -ports every url is requested on.
+	ports []portTarget
+	// closedPorts holds the scheme://host:port of unreachable ports.
+	closedPorts sync.Map
+	// confirm asks whether to go on after the first findings when set.
+	confirm *confirmer
+	// diffLines adds a diff of this many lines between the local file and
+	// the response to published findings when set.
+	diffLines int
+	// har records every request when set.
+	har *harRecorder
+	// exec is a command run for every finding.
+	exec        string
+	bothSchemes bool
+	// baseline holds the keys of known findings that are not reported.
+	baseline map[string]bool
+	// public lists paths that are served on purpose and never reported.
+	public *publicList
+	// rules give findings their severity.
+	rules severityRules
+	// dedupe shares responses between entries resolving to the same url.
+	dedupe *dedupe
+	// cache keeps recent responses when set.
+	cache *responseCache
+	// contentType compares the content type of responses with the
+	// extension of their path.
+	contentType bool
+	// captureHeaders are the response headers kept in results.
+	captureHeaders []string
+	// matchers are extra content checks of responses answering 200, by
+	// the kind of their findings.
+	matchers map[string]Matcher
+	// wafBackoff pauses requests for this long when a WAF blocks one.
+	wafBackoff time.Duration
+	backingOff int32
+	// bench records the latency of every request when set.
+	bench *bench
+	// results receives the result of every check when set.
+	results *resultWriter
+
+	gate *gate
+	// perHost bounds the requests in flight to a single host when > 0.
+	perHost int
+	// track is called when a request for an entry starts and the returned
+	// func when it is done.
+	track func(e *entry) func()
+
+	stats    stats
+	mu       sync.Mutex
+	findings []*finding
+}
+
+// scan requests every entry through the gate and returns the first error.
+// Entries not started before ctx is done are skipped. With perHost set
+// every host gets its own pool of perHost workers, so a slow host only
+// holds up its own entries.
+func (s *scanner) scan(ctx context.Context, entries []*entry) error {
+	s.dedupe = newDedupe()
+	for _, e := range entries {
+		urls, err := s.resolveAll(e)
+		if err != nil {
+			continue
+		}
+		for _, u := range urls {
+			s.dedupe.expect(fetchKey(e, u))
+		}
+	}
+
+	if s.perHost <= 0 {
+		return s.run(ctx, entries, 0)
+	}
+
+	hosts := []string{}
+	byHost := map[string][]*entry{}
+	for _, e := range entries {
+		h := ""
+		if u, err := s.resolve(e); err == nil {
+			if pu, err := url.Parse(u); err == nil {
+				h = pu.Host
+			}
+		}
+		if _, ok := byHost[h]; !ok {
+			hosts = append(hosts, h)
+		}
+		byHost[h] = append(byHost[h], e)
+	}
+
+	eg := errgroup.Group{}
+	for _, h := range hosts {
+		es := byHost[h]
+		eg.Go(func() error {
+			return s.run(ctx, es, s.perHost)
+		})
+	}
+	return eg.Wait()
+}
+
+// run feeds entries to a pool of workers which request them through the
+// gate. Without a fixed size the pool grows to the limit of the gate, so
+// raising the concurrency while scanning still adds workers.
+func (s *scanner) run(ctx context.Context, entries []*entry, size int) error {
+	ch := make(chan *entry)
+	eg := errgroup.Group{}
+	workers := 0
+feed:
+	for i, e := range entries {
+		want := size
+		if want <= 0 {
+			want, _, _ = s.gate.state()
+		}
+		for ; workers < want; workers++ {
+			eg.Go(func() error {
+				var err error
+				for e := range ch {
+					if rerr := s.work(ctx, e); rerr != nil && err == nil {
+						err = rerr
+					}
+				}
+				return err
+			})
+		}
+
+		if s.budgetSpent() {
+			s.leave(entries[i:])
+			break
+		}
+		select {
+		case ch <- e:
+		case <-ctx.Done():
+			s.leave(entries[i:])
+			break feed
+		case <-s.budget:
+			s.leave(entries[i:])
+			break feed
+		}
+	}
+	close(ch)
+	return eg.Wait()
+}
+
+func (s *scanner) work(ctx context.Context, e *entry) error {
+	if !s.gate.acquire(ctx) {
+		s.leave([]*entry{e})
+		return nil
+	}
+	defer s.gate.release()
+	if s.track != nil {
+		defer s.track(e)()
+	}
+	err := s.request(ctx, e)
+	if ctx.Err() != nil {
+		// interrupted by the deadline or an abort, so it is scanned again
+		s.leave([]*entry{e})
+	}
+	return err
+}
+
+// report records f unless it is already known from the baseline, and
+// returns whether it was recorded.
+func (s *scanner) report(f *finding) bool {
+	f.URL = displayURL(f.URL)
+	if s.baseline[f.key()] {
+		logrus.Infof("known finding in baseline %s", f.URL)
+		return false
+	}
+	if s.public.match(f.Path) {
+		logrus.Infof("%s is on the public list", f.Path)
+		return false
+	}
+	if f.Severity == "" {
+		f.Severity, f.Message = s.rules.classify(f.Path)
+	}
+	s.mu.Lock()
+	s.findings = append(s.findings, f)
+	s.mu.Unlock()
+	n := atomic.AddInt64(&s.stats.findings, 1)
+	if s.confirm != nil && n == s.confirm.after {
+		s.confirm.ask(s.gate, f, n)
+	}
+	if s.exec != "" {
+		if err := runExec(s.exec, f); err != nil {
+			logrus.Error(err)
+		}
+	}
+	return true
+}
+
+// resolve returns the URL that is requested for e.
+func (s *scanner) resolve(e *entry) (string, error) {
+	var (
+		u   string
+		err error
+	)
+	switch {
+	case isTemplate(e.URL):
+		u, err = expandTemplate(e.URL, e, s.rawPath)
+	case s.rawPath:
+		u, err = urlJoinRaw(e.URL, e.Path)
+	default:
+		u, err = urlJoin(e.URL, e.Path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.query.apply(u)
+}
+
+// resolveAll returns every URL that is requested for e, starting with
+// the one of resolve.
+func (s *scanner) resolveAll(e *entry) ([]string, error) {
+	u, err := s.resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	urls := []string{u}
+	if s.caseVariants {
+		for _, p := range caseVariants(e.Path) {
+			v, err := s.resolve(&entry{Path: p, URL: e.URL, Headers: e.Headers})
+			if err != nil {
+				return nil, err
+			}
+			urls = append(urls, v)
+		}
+	}
+	return urls, nil
+}
+
+func (s *scanner) request(ctx context.Context, e *entry) error {
+	if skip, err := s.skipLocal(e); skip || err != nil {
+		return err
+	}
+	urls, err := s.resolveAll(e)
+	if err != nil {
+		s.record(&Result{Path: e.Path, Verdict: verdictError, Error: err.Error()})
+		return err
+	}
+	if len(s.ports) == 0 {
+		for _, u := range urls {
+			res, err := s.checkSchemes(ctx, e, u)
+			if err != nil || res.Verdict == verdictPublished {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// every port is a listener of its own, so each is checked until one
+	// of the urls is published on it, and unreachable ones don't stop
+	// the scan
+	perPort := make([][]string, len(s.ports))
+	for _, u := range urls {
+		pus, err := portURLs(u, s.ports)
+		if err != nil {
+			return err
+		}
+		for i, pu := range pus {
+			perPort[i] = append(perPort[i], pu)
+		}
+	}
+	for _, urls := range perPort {
+		for _, u := range urls {
+			if s.unreachable(u) {
+				break
+			}
+			res, err := s.checkSchemes(ctx, e, u)
+			if err != nil && s.portFailed(u, res, err) {
+				return err
+			}
+			if err != nil || res.Verdict == verdictPublished {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// checkSchemes checks u, and with bothSchemes its plain http variant.
+func (s *scanner) checkSchemes(ctx context.Context, e *entry, u string) (*Result, error) {
+	res, err := s.check(ctx, e, u)
+	if err != nil || res.Verdict == verdictPublished || !s.bothSchemes || !strings.HasPrefix(u, "https://") {
+		return res, err
+	}
+
+	// many leaks are only reachable on a forgotten plain http vhost
+	return s.check(ctx, e, "http://"+strings.TrimPrefix(u, "https://"))
+}
+
+// check fetches u and reports whether it serves the local file of e. The
+// result is recorded, and is returned along with errors that stop the
+// scan.
+func (s *scanner) check(ctx context.Context, e *entry, u string) (*Result, error) {
+	res := &Result{Path: e.Path, URL: u, Verdict: verdictNotPublished}
+	defer func() {
+		s.expect(e, res)
+		s.record(res)
+	}()
+	fail := func(err error) (*Result, error) {
+		res.fail(err)
+		return res, err
+	}
+	// skipped errors are still an error for the path, but not for the scan
+	skipped := func(err error) (*Result, error) {
+		res.fail(err)
+		return res, nil
+	}
+
+	f, err := s.fetchers.forURL(u)
+	if err != nil {
+		return fail(err)
+	}
+
+	// the local file is read first, so that the body is compared with it
+	// while it is streamed
+	path := s.localPath(e)
+	hashed := e.Head == nil && s.local.large(path)
+	lines, localErr := e.Head, error(nil)
+	if !hashed && lines == nil {
+		lines, localErr = getFileHead(path)
+	}
+	var m *bodyMatcher
+	if hashed {
+		m = newHashMatcher()
+	} else {
+		m = newLineMatcher(lines)
+	}
+	streamed := false
+
+	start := time.Now()
+	key := fetchKey(e, u)
+	r, shared := s.cache.get(key)
+	if !shared {
+		r, shared, err = s.dedupe.do(key, func() (*Response, error) {
+			streamed = true
+			r, err := s.fetch(withMatcher(ctx, m), f, e, u)
+			if err == nil && !r.truncated() {
+				s.cache.add(key, r)
+			}
+			return r, err
+		})
+	}
+	if shared && err == nil && r.truncated() {
+		// only the head of the body was kept, so it has to be streamed
+		// again through the matcher of e
+		shared, streamed = false, true
+		r, err = s.fetch(withMatcher(ctx, m), f, e, u)
+	}
+	res.Duration = time.Since(start)
+	if shared {
+		logrus.Debugf("reusing the response of %s for %s", u, e.Path)
+	}
+	if err != nil {
+		if s.skipError(classifyFetchError(err), err) {
+			return skipped(err)
+		}
+		return fail(err)
+	}
+	res.Status = r.StatusCode
+	res.Headers = captureHeaders(r.Header, s.captureHeaders)
+	if !streamed {
+		m.Write(r.Body)
+	}
+
+	if e.storage != "" && r.StatusCode != http.StatusOK {
+		if err := bucketStatus(u, r.Status, r.Body); err != nil {
+			return fail(err)
+		}
+		return res, nil
+	}
+
+	if waf := detectWAF(r); waf != "" {
+		s.blocked(e, u, waf)
+		res.Verdict, res.Error = verdictBlocked, "blocked by "+waf
+		return res, nil
+	}
+
+	st := fmt.Sprintf("request: %s %s", u, r.Status)
+	if r.StatusCode != http.StatusOK &&
+		r.StatusCode != http.StatusNotFound &&
+		r.StatusCode != http.StatusForbidden {
+		logrus.Warnf(st)
+		res.Verdict, res.Error, res.ErrorKind = verdictError, "unexpected status "+r.Status, errorKindStatus
+		return res, nil
+	} else {
+		logrus.Infof(st)
+	}
+	if s.generic != nil {
+		s.generic.observe(u, r)
+	}
+	if s.contentType && r.StatusCode == http.StatusOK {
+		s.checkContentType(e, u, r)
+	}
+	if len(s.matchers) > 0 && r.StatusCode == http.StatusOK {
+		s.match(ctx, e, u, r)
+	}
+	if r.StatusCode == http.StatusOK {
+		s.checkHeaders(e, u, r)
+	}
+	if hashed {
+		if r.StatusCode != http.StatusOK {
+			return res, nil
+		}
+		same, err := sameHash(path, m.sum())
+		if err != nil {
+			if s.skipError(errorLocal, err) {
+				return skipped(err)
+			}
+			return fail(err)
+		}
+		if !same {
+			return res, nil
+		}
+		res.Verdict, res.Evidence = verdictPublished, s.publish(e, u, r)
+		return res, nil
+	}
+
+	if localErr != nil {
+		if os.IsNotExist(localErr) && (e.source != "" || e.Expect != 0) {
+			if e.source != "" {
+				logrus.Infof("%s is listed in %s but not in the local tree", e.Path, e.source)
+			}
+			// only the status of paths with an expectation is checked
+			res.Verdict, res.Error = verdictSkipped, "not in the local tree"
+			return res, nil
+		}
+		if s.skipError(errorLocal, localErr) {
+			f := &finding{Kind: findingUnverified, Path: e.Path, URL: u, Time: time.Now()}
+			if s.probeUnreadable && r.StatusCode == http.StatusOK && s.report(f) {
+				findingLog(f).Warnf("This file may be published %s at %s, the local file can't be read to compare", e.Path, u)
+			}
+			return skipped(localErr)
+		}
+		return fail(localErr)
+	}
+
+	if !m.matched() {
+		return res, nil
+	}
+	res.Verdict, res.Evidence = verdictPublished, s.publish(e, u, r)
+	return res, nil
+}
+
+// publish reports that u serves the local file of e, and returns the
+// evidence file written for it, if any.
+func (s *scanner) publish(e *entry, u string, r *Response) string {
+	f := &finding{Kind: findingPublished, Path: e.Path, URL: u, Time: time.Now()}
+	if s.diffLines > 0 {
+		f.Diff = s.diff(e, u, r)
+	}
+	if s.generic != nil {
+		s.generic.downgrade(f, pageFingerprint(u, r), r)
+	}
+	// evidence is written first so that -exec can attach it
+	if s.evidenceDir != "" && !s.baseline[f.key()] && !s.public.match(f.Path) {
+		f.Evidence = evidenceFile(s.evidenceDir, f)
+		if err := writeEvidence(f.Evidence, u, r); err != nil {
+			logrus.Error(err)
+		}
+	}
+	if s.report(f) {
+		findingLog(f).Warnf("This file is published %s at %s", e.Path, u)
+		if f.Diff != "" {
+			logrus.Warnf("the response differs from the local file:\n%s", f.Diff)
+		}
+	}
+	return f.Evidence
+}
+
+// fetch requests u with f, counting and recording the request.
+func (s *scanner) fetch(ctx context.Context, f Fetcher, e *entry, u string) (*Response, error) {
+	atomic.AddInt64(&s.stats.requests, 1)
+	start := time.Now()
+	r, err := f.Fetch(ctx, e, u)
+	d := time.Since(start)
+	if s.har != nil {
+		s.har.add(u, start, d, r, err)
+	}
+	if s.bench != nil {
+		s.bench.add(d)
+	}
+	return r, err
+}
+
+// probe requests a path that isn't a local file over http(s). The
+// response is nil when the url isn't http or the error was skipped.
+func (s *scanner) probe(ctx context.Context, e *entry) (string, *Response, error) {
+	u, err := s.resolve(e)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return u, nil, nil
+	}
+	f, err := s.fetchers.forURL(u)
+	if err != nil {
+		return u, nil, err
+	}
+
+	r, err := s.fetch(ctx, f, e, u)
+	if err != nil {
+		if s.skipError(classifyFetchError(err), err) {
+			return u, nil, nil
+		}
+		return u, nil, err
+	}
+	logrus.Infof("request: %s %s", u, r.Status)
+	return u, r, nil
+}
+
+// probeAll requests es through the gate and reports the ones answering
+// 200 with a body that match accepts as findings of kind. msg is logged
+// with the path and url of each.
+func (s *scanner) probeAll(ctx context.Context, es []*entry, kind, msg string, match func(e *entry, r *Response) bool) error {
+	eg := errgroup.Group{}
+	for _, e := range es {
+		if !s.gate.acquire(ctx) {
+			break
+		}
+		e := e
+		eg.Go(func() error {
+			defer s.gate.release()
+			u, r, err := s.probe(ctx, e)
+			res := &Result{Path: e.Path, URL: u, Verdict: verdictNotPublished}
+			defer s.record(res)
+			switch {
+			case err != nil:
+				res.fail(err)
+				return err
+			case r == nil:
+				res.Verdict = verdictSkipped
+				return nil
+			}
+			res.Status = r.StatusCode
+			if r.StatusCode != http.StatusOK || !match(e, r) {
+				return nil
+			}
+			res.Verdict = verdictPublished
+			f := &finding{Kind: kind, Path: e.Path, URL: u, Time: time.Now()}
+			if s.report(f) {
+				findingLog(f).Warnf(msg, e.Path, u)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// checkHeaders reports paths served with a header of a header rule.
+func (s *scanner) checkHeaders(e *entry, u string, r *Response) {
+	for _, rule := range s.rules.headerRules(e.Path, r.Header) {
+		f := &finding{Kind: findingHeader, Path: e.Path, URL: u, Time: time.Now(), Severity: rule.severity, Message: rule.message}
+		if s.report(f) {
+			findingLog(f).Warnf("This file is served with %s: %s %s at %s", rule.header, r.Header.Get(rule.header), e.Path, u)
+		}
+	}
+}
+
+// captureHeaders returns the values of names in h, or nil without any.
+func captureHeaders(h http.Header, names []string) map[string]string {
+	var captured map[string]string
+	for _, n := range names {
+		if v := h.Get(n); v != "" {
+			if captured == nil {
+				captured = map[string]string{}
+			}
+			captured[http.CanonicalHeaderKey(n)] = v
+		}
+	}
+	return captured
+}
+
+// checkContentType reports scripts served as source and warns about other
+// content types that don't match the extension of e.
+func (s *scanner) checkContentType(e *entry, u string, r *Response) {
+	source, got, expected := checkContentType(e.Path, r.Header)
+	if source {
+		f := &finding{Kind: findingSource, Path: e.Path, URL: u, Time: time.Now()}
+		if s.report(f) {
+			findingLog(f).Warnf("This script is served as %s instead of being run %s at %s", got, e.Path, u)
+		}
+	} else if expected != "" {
+		logrus.Warnf("%s is served as %s, expected %s", u, got, expected)
+	}
+}
+
+func getFileHead(path string) ([]string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return readHead(fp), nil
+}
+
+func readHead(r io.Reader) []string {
+	cnt := 0
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, 0, initScanTokenSize)
+	scanner.Buffer(buf, MaxScanTokenSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		cnt++
+		if cnt > 10 {
+			break
+		}
+	}
+	return lines
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanner_check(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.php"), []byte("<?php\n$password = 'x';\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.php"), []byte("<?php\necho 'hello';\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/secret.php":
+			fmt.Fprint(w, "<?php\n$password = 'x';\n")
+		case "/index.php":
+			fmt.Fprint(w, "hello")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	s := &scanner{fetchers: newFetchers(ts.Client(), 3, &tls.Config{}, nil)}
+	for name, expected := range map[string]bool{"secret.php": true, "index.php": false} {
+		e := &entry{Path: filepath.Join(dir, name), URL: ts.URL}
+		res, err := s.check(context.Background(), e, ts.URL+"/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if published := res.Verdict == verdictPublished; published != expected {
+			t.Errorf("%s: expected %v to eq %v", name, published, expected)
+		}
+	}
+}
+
+func TestScanner_scanPerHost(t *testing.T) {
+	var inFlight, maxInFlight int64
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			m := atomic.LoadInt64(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt64(&maxInFlight, m, n) {
+				break
+			}
+		}
+		<-release
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer slow.Close()
+	var fastDone int64
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&fastDone, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer fast.Close()
+
+	dir := t.TempDir()
+	entries := []*entry{}
+	for i := 0; i < 3; i++ {
+		// distinct paths, as the same url is only requested once
+		p := filepath.Join(dir, fmt.Sprintf("index%d.php", i))
+		if err := os.WriteFile(p, []byte("<?php"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, &entry{Path: p, URL: slow.URL}, &entry{Path: p, URL: fast.URL})
+	}
+
+	ctx := context.Background()
+	s := &scanner{
+		fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil),
+		gate:     newGate(ctx, 4),
+		perHost:  1,
+	}
+	done := make(chan error)
+	go func() { done <- s.scan(ctx, entries) }()
+
+	for i := 0; atomic.LoadInt64(&fastDone) < 3 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&fastDone); n != 3 {
+		t.Errorf("expected the fast host to finish while the slow one hangs, got %d requests", n)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if maxInFlight != 1 {
+		t.Errorf("expected %d requests in flight to the slow host, got %d", 1, maxInFlight)
+	}
+}
+
+func TestScanner_scanPool(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer target.Close()
+	p := filepath.Join(t.TempDir(), "index.php")
+	if err := os.WriteFile(p, []byte("<?php"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]*entry, 500)
+	for i := range entries {
+		entries[i] = &entry{Path: p, URL: target.URL}
+	}
+
+	ctx := context.Background()
+	base := runtime.NumGoroutine()
+	var done, peak int64
+	s := &scanner{
+		fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		gate:     newGate(ctx, 3),
+	}
+	s.track = func(e *entry) func() {
+		if n := int64(runtime.NumGoroutine() - base); n > atomic.LoadInt64(&peak) {
+			atomic.StoreInt64(&peak, n)
+		}
+		return func() { atomic.AddInt64(&done, 1) }
+	}
+	if err := s.scan(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if done != 500 {
+		t.Errorf("expected %d entries to be requested, got %d", 500, done)
+	}
+	// 3 workers plus the connections of the http client
+	if peak > 30 {
+		t.Errorf("expected a bounded number of goroutines, got %d", peak)
+	}
+}
+
+func TestScanner_skipErrors(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer target.Close()
+	missing := &entry{Path: filepath.Join(t.TempDir(), "missing.php"), URL: target.URL}
+	unresolvable := &entry{Path: "./index.php", URL: "http://pmr.invalid/"}
+
+	ctx := context.Background()
+	tests := []struct {
+		skip  skipPolicy
+		e     *entry
+		err   bool
+		local int64
+		dns   int64
+	}{
+		{skipPolicy{}, missing, true, 1, 0},
+		{skipPolicy{local: true}, missing, false, 1, 0},
+		{skipPolicy{network: true}, unresolvable, true, 0, 1},
+		{skipPolicy{dns: true}, unresolvable, false, 0, 1},
+	}
+	for i, tt := range tests {
+		s := &scanner{fetchers: newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), skip: tt.skip}
+		err := s.request(ctx, tt.e)
+		if (err != nil) != tt.err {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if s.stats.localErrors != tt.local || s.stats.dnsErrors != tt.dns || s.stats.errors != tt.local+tt.dns {
+			t.Errorf("%d: unexpected stats %s", i, s.stats.summary())
+		}
+	}
+}
+
+func TestScanner_probeUnreadable(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "dump")
+	}))
+	defer target.Close()
+
+	s := &scanner{
+		fetchers:        newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		skip:            skipPolicy{local: true},
+		probeUnreadable: true,
+	}
+	e := &entry{Path: filepath.Join(t.TempDir(), "dump.sql"), URL: target.URL}
+	if err := s.request(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].Kind != findingUnverified {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+}
+
+func TestScanner_root(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var requested string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root}
+	if err := s.request(context.Background(), &entry{Path: "./secret.php", URL: target.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || requested != "/secret.php" {
+		t.Errorf("unexpected findings %+v for %s", s.findings, requested)
+	}
+}
+
+func TestScanner_caseVariants(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "Secret.PHP"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secret.php" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root, caseVariants: true}
+	if err := s.request(context.Background(), &entry{Path: "./Secret.PHP", URL: target.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].URL != target.URL+"/secret.php" {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+	if s.stats.requests != 2 {
+		t.Errorf("expected %d requests, got %d", 2, s.stats.requests)
+	}
+}
+
+func TestScanner_dedupe(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a", "b", "c"} {
+		os.MkdirAll(filepath.Join(root, d), 0755)
+		if err := os.WriteFile(filepath.Join(root, d, "jquery.js"), []byte("/*! jQuery */"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var hits int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		fmt.Fprint(w, "/*! jQuery */")
+	}))
+	defer target.Close()
+
+	entries := []*entry{}
+	for _, d := range []string{"a", "b", "c"} {
+		entries = append(entries, &entry{Path: "./" + d + "/jquery.js", URL: target.URL + "/js/{base}"})
+	}
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root, gate: newGate(ctx, 3)}
+	if err := s.scan(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 1 || s.stats.requests != 1 {
+		t.Errorf("expected the url to be requested once, got %d hits and %d requests", hits, s.stats.requests)
+	}
+	if len(s.findings) != 3 {
+		t.Errorf("expected every entry to be compared, got findings %+v", s.findings)
+	}
+}
+
+func TestScanner_largeBody(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		os.MkdirAll(filepath.Join(root, d), 0755)
+		if err := os.WriteFile(filepath.Join(root, d, "dump.sql"), []byte("-- dump "+d), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var hits int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		fmt.Fprint(w, strings.Repeat("x", 2*maxBodyBuffer))
+		fmt.Fprint(w, "\n-- dump b\n")
+	}))
+	defer target.Close()
+
+	entries := []*entry{
+		{Path: "./a/dump.sql", URL: target.URL + "/{base}"},
+		{Path: "./b/dump.sql", URL: target.URL + "/{base}"},
+	}
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root, gate: newGate(ctx, 1)}
+	if err := s.scan(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].Path != "./b/dump.sql" {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+	// the truncated response can't be shared, so each entry streams it
+	if hits != 2 {
+		t.Errorf("expected %d requests, got %d", 2, hits)
+	}
+}
+
+func TestScanner_results(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "secret.php"), []byte("<?php secret"), 0644)
+	os.WriteFile(filepath.Join(root, "index.php"), []byte("<?php index"), 0644)
+	os.Symlink("secret.php", filepath.Join(root, "link.php"))
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/secret.php":
+			fmt.Fprint(w, "<?php secret")
+		case "/error.php":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	b := &bytes.Buffer{}
+	s := &scanner{
+		fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		root:     root,
+		local:    localPolicy{skipSymlinks: true},
+		results:  &resultWriter{w: b},
+	}
+	for _, p := range []string{"./secret.php", "./index.php", "./link.php", "./error.php"} {
+		s.request(context.Background(), &entry{Path: p, URL: target.URL})
+	}
+
+	verdicts := []string{}
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		res := &Result{}
+		if err := json.Unmarshal([]byte(l), res); err != nil {
+			t.Fatal(err)
+		}
+		verdicts = append(verdicts, fmt.Sprintf("%s %s %d", res.Path, res.Verdict, res.Status))
+	}
+	expect := fmt.Sprint([]string{
+		"./secret.php published 200",
+		"./index.php not-published 404",
+		"./link.php skipped 0",
+		"./error.php error 500",
+	})
+	if fmt.Sprint(verdicts) != expect {
+		t.Errorf("expected %q to eq %q", fmt.Sprint(verdicts), expect)
+	}
+}
+
+func TestScanner_expect(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".env"), []byte("SECRET=1"), 0644)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.env":
+			fmt.Fprint(w, "SECRET=1")
+		case "/health":
+			fmt.Fprint(w, "ok")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+
+	b := &bytes.Buffer{}
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: root, results: &resultWriter{w: b}}
+	for _, e := range []*entry{
+		{Path: "./.env", URL: target.URL, Expect: 404},
+		{Path: "./health", URL: target.URL, Expect: 200},
+		{Path: "./robots.txt", URL: target.URL, Expect: 200},
+	} {
+		if err := s.request(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.stats.unmet != 2 {
+		t.Errorf("expected %d unmet expectations, got %d", 2, s.stats.unmet)
+	}
+	if !strings.Contains(b.String(), `"path":"./health","url":"`+target.URL+`/health","status":200,"verdict":"skipped","error":"not in the local tree","expect":200,"duration"`) {
+		t.Errorf("expected the met expectation in %s", b.String())
+	}
+}
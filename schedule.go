@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runSchedules starts the configured scans at every minute their cron
+// expression matches. A schedule is skipped while its previous run is
+// still going.
+func (sv *server) runSchedules(ctx context.Context) {
+	running := map[string]bool{}
+	finished := make(chan string)
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(next.Sub(now))
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case name := <-finished:
+				delete(running, name)
+			case <-timer.C:
+				break wait
+			}
+		}
+
+		for _, sc := range sv.schedules {
+			if !sc.cron.match(next) {
+				continue
+			}
+			if running[sc.Name] {
+				logrus.Warnf("schedule %s: previous scan is still running, skipped", sc.Name)
+				continue
+			}
+			running[sc.Name] = true
+			go func(sc *scheduleConfig) {
+				defer func() {
+					select {
+					case finished <- sc.Name:
+					case <-ctx.Done():
+					}
+				}()
+				if err := sv.runSchedule(ctx, sc); err != nil {
+					logrus.Errorf("schedule %s: %s", sc.Name, err)
+				}
+			}(sc)
+		}
+	}
+}
+
+// runSchedule loads the paths of sc, scans them and sends the result to
+// its notification channel.
+func (sv *server) runSchedule(ctx context.Context, sc *scheduleConfig) error {
+	entries, err := scheduleEntries(ctx, sv.client, sc)
+	if err != nil {
+		return err
+	}
+	concurrency := sc.Concurrency
+	if concurrency <= 0 {
+		concurrency = sv.concurrency
+	}
+
+	job, err := sv.start(sc.Name, sc.URL, entries, concurrency)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("schedule %s: started scan %s of %d paths", sc.Name, job.ID, len(entries))
+	<-job.done
+
+	v := sv.snapshot(job)
+	if sc.Notify.Webhook != "" {
+		if err := notifyWebhook(ctx, sv.client, sc.Notify.Webhook, v); err != nil {
+			return err
+		}
+	}
+	if len(sc.Notify.Email) > 0 && len(v.Findings) > 0 {
+		return sendDigest(sv.smtp, sc.Notify.Email, sc.URL, v.Findings, "/scans/"+v.ID)
+	}
+	return nil
+}
+
+func scheduleEntries(ctx context.Context, client *http.Client, sc *scheduleConfig) ([]*entry, error) {
+	entries := []*entry{}
+	if sc.Paths != "" {
+		body, err := ioutil.ReadFile(sc.Paths)
+		if err != nil {
+			return nil, err
+		}
+		es, err := parseEntries(body, sc.InputFormat, sc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", sc.Paths, err)
+		}
+		entries = append(entries, es...)
+	}
+	if sc.Sitemap != "" {
+		es, err := sitemapEntries(ctx, client, sc.Sitemap, sc.URL)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	if sc.Robots != "" {
+		es, err := robotsEntries(ctx, client, sc.Robots, sc.URL)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	return entries, nil
+}
+
+// webhookMessage is posted to notification webhooks. The text field makes
+// it readable as a Slack incoming webhook message.
+type webhookMessage struct {
+	Text string   `json:"text"`
+	Scan *scanJob `json:"scan"`
+}
+
+func notifyWebhook(ctx context.Context, client *http.Client, u string, job *scanJob) error {
+	text := fmt.Sprintf("%s: scan %s of %s %s, %d findings", Name, job.Schedule, job.URL, job.Status, len(job.Findings))
+	for _, f := range job.Findings {
+		text += fmt.Sprintf("\n%s %s", f.Kind, f.URL)
+	}
+	b, err := json.Marshal(&webhookMessage{Text: text, Scan: job})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", u, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tokenEnv is the environment variable the API token is read from by
+// default, by serve and by scan -workers.
+const tokenEnv = "PMR_TOKEN"
+
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// scanRequest is the body of POST /scans. Paths inherit URL, entries may
+// carry their own url and headers like NDJSON input.
+type scanRequest struct {
+	URL         string   `json:"url"`
+	Paths       []string `json:"paths"`
+	Entries     []*entry `json:"entries"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// scanJob is a scan started through the API.
+type scanJob struct {
+	ID         string     `json:"id"`
+	Schedule   string     `json:"schedule,omitempty"`
+	Status     string     `json:"status"`
+	URL        string     `json:"url"`
+	Total      int        `json:"total"`
+	Requests   int64      `json:"requests"`
+	Errors     int64      `json:"errors"`
+	Findings   []*finding `json:"findings"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	scanner *scanner
+	done    chan struct{}
+}
+
+// server runs scans on behalf of API clients and on the schedules of its
+// config file. Local paths are read relative to the working directory of
+// the server.
+type server struct {
+	fetchers    fetchers
+	client      *http.Client
+	concurrency int
+	schedules   []*scheduleConfig
+	smtp        *smtpConfig
+	// db records every finished scan when set.
+	db *resultDB
+	// token is required as a bearer token on every API request when set.
+	token string
+
+	mu   sync.Mutex
+	jobs map[string]*scanJob
+}
+
+// runServe starts the HTTP API.
+func (cli *CLI) runServe(args []string) int {
+	var (
+		listen      string
+		concurrency int
+		timeout     int
+		insecure    bool
+		configPath  string
+		dbPath      string
+		retain      string
+		token       string
+	)
+
+	flags := flag.NewFlagSet(Name+" serve", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.StringVar(&listen, "listen", "127.0.0.1:8080", "address to listen on")
+	flags.StringVar(&token, "token", os.Getenv(tokenEnv), "bearer token required on API requests, $"+tokenEnv+" by default")
+	flags.IntVar(&concurrency, "concurrency", 5, "default request concurrency of a scan")
+	flags.IntVar(&timeout, "request-timeout", 3, "request timeout sec")
+	flags.BoolVar(&insecure, "insecure", false, "Allow connections to SSL sites without certs")
+	flags.BoolVar(&insecure, "k", false, "Allow connections to SSL sites without certs(Short)")
+	flags.StringVar(&configPath, "config", "", "config file with scheduled scans")
+	flags.StringVar(&dbPath, "db", "", "record every finished scan and its findings in this SQLite database")
+	flags.StringVar(&retain, "retain", "", "forget scans finished longer ago than this, e.g. 90d, checked every hour")
+	if err := cli.parse(flags, args[1:]); err != nil {
+		return ExitCodeError
+	}
+	setupLogger(cli.errStream, true)
+	if err := checkListen(listen, token); err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+
+	tlsConfig, err := newTLSConfig(insecure, "", "", "")
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	client := newHTTPClient(timeout, tlsConfig, protoAuto, nil, transportTimeouts{})
+	sv := newServer(newFetchers(client, timeout, tlsConfig, nil), concurrency)
+	sv.client = client
+	sv.token = token
+	// opened before the schedules start, which record their scans in it
+	if dbPath != "" {
+		if sv.db, err = openResultDB(dbPath); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		defer sv.db.Close()
+	}
+	if configPath != "" {
+		c, err := loadConfig(configPath)
+		if err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		sv.schedules = c.Schedules
+		sv.smtp = c.SMTP
+		go sv.runSchedules(context.Background())
+	}
+	if retain != "" {
+		if _, err := parseSince(retain, time.Now()); err != nil {
+			logrus.Error(err)
+			return ExitCodeError
+		}
+		go sv.runRetention(context.Background(), retain)
+	}
+
+	logrus.Infof("listen on %s", listen)
+	if err := http.ListenAndServe(listen, sv.handler()); err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	return ExitCodeOK
+}
+
+func newServer(fs fetchers, concurrency int) *server {
+	return &server{
+		fetchers:    fs,
+		concurrency: concurrency,
+		jobs:        map[string]*scanJob{},
+	}
+}
+
+// checkListen refuses to serve the API beyond the loopback interface
+// without a token, since a scan requests any url and reads local files.
+func checkListen(listen, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listening on %s needs a -token", listen)
+}
+
+func (sv *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scans", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			sv.createScan(w, r)
+		case http.MethodGet:
+			sv.listScans(w, r)
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+	mux.HandleFunc("/scans/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		sv.mu.Lock()
+		job, ok := sv.jobs[strings.TrimPrefix(r.URL.Path, "/scans/")]
+		sv.mu.Unlock()
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "scan not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, sv.snapshot(job))
+	})
+	if sv.token == "" {
+		return mux
+	}
+	want := []byte("Bearer " + sv.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (sv *server) createScan(w http.ResponseWriter, r *http.Request) {
+	req := &scanRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries := req.Entries
+	for _, p := range req.Paths {
+		entries = append(entries, &entry{Path: p})
+	}
+	if len(entries) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "paths or entries are required")
+		return
+	}
+	for _, e := range entries {
+		if e.URL == "" {
+			e.URL = req.URL
+		}
+		if e.Path == "" || e.URL == "" {
+			writeJSONError(w, http.StatusBadRequest, "every path needs a url")
+			return
+		}
+	}
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = sv.concurrency
+	}
+
+	job, err := sv.start("", req.URL, entries, concurrency)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, sv.snapshot(job))
+}
+
+// start runs a scan of entries in the background. The done channel of the
+// returned job is closed when the scan has finished.
+func (sv *server) start(schedule, u string, entries []*entry, concurrency int) (*scanJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	// the gate of the job watches ctx until it is cancelled, so every job
+	// gets its own
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &scanJob{
+		ID:        id,
+		Schedule:  schedule,
+		Status:    jobRunning,
+		URL:       u,
+		Total:     len(entries),
+		StartedAt: time.Now(),
+		scanner: &scanner{
+			fetchers: sv.fetchers,
+			skip:     skipPolicy{network: true, dns: true},
+			gate:     newGate(ctx, concurrency),
+		},
+		done: make(chan struct{}),
+	}
+	sv.mu.Lock()
+	sv.jobs[id] = job
+	sv.mu.Unlock()
+
+	go func() {
+		defer close(job.done)
+		defer cancel()
+		err := job.scanner.scan(ctx, entries)
+		now := time.Now()
+		if sv.db != nil {
+			if err := sv.db.saveScan(u, job.StartedAt, now, &job.scanner.stats, job.scanner.findings); err != nil {
+				logrus.Errorf("scan %s: %s", id, err)
+			}
+		}
+
+		sv.mu.Lock()
+		defer sv.mu.Unlock()
+		job.FinishedAt = &now
+		job.Status = jobDone
+		if err != nil {
+			job.Status = jobFailed
+			job.Error = err.Error()
+		}
+		logrus.Infof("scan %s %s", id, job.Status)
+	}()
+	return job, nil
+}
+
+func (sv *server) listScans(w http.ResponseWriter, r *http.Request) {
+	sv.mu.Lock()
+	jobs := make([]*scanJob, 0, len(sv.jobs))
+	for _, job := range sv.jobs {
+		jobs = append(jobs, job)
+	}
+	sv.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.Before(jobs[j].StartedAt) })
+	views := make([]*scanJob, 0, len(jobs))
+	for _, job := range jobs {
+		views = append(views, sv.snapshot(job))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// snapshot copies job with the current progress of its scanner.
+func (sv *server) snapshot(job *scanJob) *scanJob {
+	sv.mu.Lock()
+	v := *job
+	sv.mu.Unlock()
+
+	s := job.scanner
+	v.Requests = atomic.LoadInt64(&s.stats.requests)
+	v.Errors = atomic.LoadInt64(&s.stats.errors)
+	s.mu.Lock()
+	v.Findings = append([]*finding{}, s.findings...)
+	s.mu.Unlock()
+	return &v
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Error(err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_scan(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	sv := newServer(newFetchers(target.Client(), 3, &tls.Config{}, nil), 2)
+	api := httptest.NewServer(sv.handler())
+	defer api.Close()
+
+	body, _ := json.Marshal(&scanRequest{URL: target.URL, Paths: []string{filepath.Join(dir, "secret.php")}})
+	r, err := http.Post(api.URL+"/scans", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &scanJob{}
+	json.NewDecoder(r.Body).Decode(job)
+	r.Body.Close()
+	if r.StatusCode != http.StatusAccepted || job.ID == "" {
+		t.Fatalf("unexpected response %d %+v", r.StatusCode, job)
+	}
+
+	for i := 0; job.Status == jobRunning && i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		r, err := http.Get(api.URL + r.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(r.Body).Decode(job)
+		r.Body.Close()
+	}
+	if job.Status != jobDone || len(job.Findings) != 1 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	r, err = http.Get(api.URL + "/scans/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d to eq %d", r.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestServer_runSchedule(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.php")
+	if err := os.WriteFile(secret, []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list := filepath.Join(dir, "paths.txt")
+	if err := os.WriteFile(list, []byte(secret+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	messages := make(chan *webhookMessage, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := &webhookMessage{}
+		json.NewDecoder(r.Body).Decode(m)
+		messages <- m
+	}))
+	defer hook.Close()
+
+	sv := newServer(newFetchers(target.Client(), 3, &tls.Config{}, nil), 2)
+	sv.client = hook.Client()
+	sc := &scheduleConfig{
+		Name:        "nightly",
+		URL:         target.URL,
+		Paths:       list,
+		InputFormat: inputFormatPlain,
+		Notify:      notifyConfig{Webhook: hook.URL},
+	}
+	if err := sv.runSchedule(context.Background(), sc); err != nil {
+		t.Fatal(err)
+	}
+
+	m := <-messages
+	if m.Scan.Schedule != "nightly" || m.Scan.Status != jobDone || len(m.Scan.Findings) != 1 {
+		t.Errorf("unexpected scan %+v", m.Scan)
+	}
+	if !strings.Contains(m.Text, "1 findings") {
+		t.Errorf("expected %q to contain %q", m.Text, "1 findings")
+	}
+}
+
+func TestServer_token(t *testing.T) {
+	sv := newServer(newFetchers(http.DefaultClient, 3, &tls.Config{}, nil), 2)
+	sv.token = "secret"
+	api := httptest.NewServer(sv.handler())
+	defer api.Close()
+
+	for auth, expected := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req, err := http.NewRequest(http.MethodGet, api.URL+"/scans", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != expected {
+			t.Errorf("%q: expected %d to eq %d", auth, res.StatusCode, expected)
+		}
+	}
+}
+
+func TestCheckListen(t *testing.T) {
+	for _, tt := range []struct {
+		listen, token string
+		ok            bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{"localhost:8080", "", true},
+		{"[::1]:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{":8080", "secret", true},
+	} {
+		if err := checkListen(tt.listen, tt.token); (err == nil) != tt.ok {
+			t.Errorf("%s %q: unexpected error %v", tt.listen, tt.token, err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	severityInfo     = "info"
+	severityLow      = "low"
+	severityMedium   = "medium"
+	severityHigh     = "high"
+	severityCritical = "critical"
+
+	// severityDefault is given to findings no rule matches.
+	severityDefault = severityMedium
+)
+
+var severities = []string{severityInfo, severityLow, severityMedium, severityHigh, severityCritical}
+
+// severityRule gives the severity and an optional message to findings
+// whose path matches pattern. `**` in a pattern matches any number of
+// directories. A rule with a header doesn't classify other findings, it
+// reports the paths served with that header instead.
+type severityRule struct {
+	pattern  string
+	severity string
+	message  string
+	header   string
+	// value is matched as a case-insensitive substring of the header.
+	// Without one, the rule matches any value.
+	value string
+}
+
+// defaultSeverityRules are applied after the rules of -rules.
+var defaultSeverityRules = []*severityRule{
+	{pattern: "**/*.pem", severity: severityCritical},
+	{pattern: "**/*.key", severity: severityCritical},
+	{pattern: "**/*.p12", severity: severityCritical},
+	{pattern: "**/*.pfx", severity: severityCritical},
+	{pattern: "**/id_rsa*", severity: severityCritical},
+	{pattern: "**/.env", severity: severityCritical},
+	{pattern: "**/.env.*", severity: severityCritical},
+	{pattern: "**/wp-config.php", severity: severityCritical},
+	{pattern: "**/config.php", severity: severityCritical},
+	{pattern: "**/web.config", severity: severityCritical},
+	{pattern: "**/.git/**", severity: severityHigh},
+	{pattern: "**/.svn/**", severity: severityHigh},
+	{pattern: "**/.hg/**", severity: severityHigh},
+	{pattern: "**/*.sql", severity: severityHigh},
+	{pattern: "**/*.sqlite", severity: severityHigh},
+	{pattern: "**/*.dump", severity: severityHigh},
+	{pattern: "**/*.bak", severity: severityHigh},
+	{pattern: "**/*.yml", severity: severityHigh},
+	{pattern: "**/*.yaml", severity: severityHigh},
+	{pattern: "**/*.ini", severity: severityHigh},
+	{pattern: "**/*.conf", severity: severityHigh},
+	{pattern: "**/*.properties", severity: severityHigh},
+	{pattern: "**/*.log", severity: severityMedium},
+	{pattern: "**/*.map", severity: severityLow},
+}
+
+// severityRules are checked in order and the first match wins.
+type severityRules []*severityRule
+
+// readSeverityRules reads a YAML mapping of patterns to either a severity
+// or a severity and message, followed by the default rules.
+func readSeverityRules(p string) (severityRules, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	// a MapSlice keeps the order of the file
+	ms := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &ms); err != nil {
+		return nil, fmt.Errorf("%s: %s", p, err)
+	}
+
+	rules := severityRules{}
+	for _, item := range ms {
+		r, err := parseSeverityRule(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		rules = append(rules, r)
+	}
+	return append(rules, defaultSeverityRules...), nil
+}
+
+func parseSeverityRule(item yaml.MapItem) (*severityRule, error) {
+	pattern, ok := item.Key.(string)
+	if !ok {
+		return nil, fmt.Errorf("pattern %v is not a string", item.Key)
+	}
+	r := &severityRule{pattern: listPath(pattern)}
+	for _, seg := range strings.Split(r.pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("%s: %s", pattern, err)
+		}
+	}
+
+	switch v := item.Value.(type) {
+	case string:
+		r.severity = v
+	case yaml.MapSlice:
+		for _, f := range v {
+			s, ok := f.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: %v is not a string", pattern, f.Key)
+			}
+			switch f.Key {
+			case "severity":
+				r.severity = s
+			case "message":
+				r.message = s
+			case "header":
+				kv := strings.SplitN(s, ":", 2)
+				r.header = http.CanonicalHeaderKey(strings.TrimSpace(kv[0]))
+				if len(kv) == 2 {
+					r.value = strings.ToLower(strings.TrimSpace(kv[1]))
+				}
+				if r.header == "" {
+					return nil, fmt.Errorf("%s: empty header", pattern)
+				}
+			default:
+				return nil, fmt.Errorf("%s: unknown field %v", pattern, f.Key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected a severity or a mapping of severity and message", pattern)
+	}
+
+	if !validSeverity(r.severity) {
+		return nil, fmt.Errorf("%s: unknown severity %q, expected one of %s", pattern, r.severity, strings.Join(severities, ", "))
+	}
+	return r, nil
+}
+
+func validSeverity(s string) bool {
+	for _, v := range severities {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// classify returns the severity and message of the first rule matching p.
+// A nil list applies the default rules.
+func (rs severityRules) classify(p string) (string, string) {
+	if rs == nil {
+		rs = defaultSeverityRules
+	}
+	segs := strings.Split(listPath(p), "/")
+	for _, r := range rs {
+		if r.header == "" && globMatch(strings.Split(r.pattern, "/"), segs) {
+			return r.severity, r.message
+		}
+	}
+	return severityDefault, ""
+}
+
+// headerRules returns the header rules matching p whose header is in h.
+func (rs severityRules) headerRules(p string, h http.Header) []*severityRule {
+	segs := strings.Split(listPath(p), "/")
+	matched := []*severityRule{}
+	for _, r := range rs {
+		if r.header == "" || !globMatch(strings.Split(r.pattern, "/"), segs) {
+			continue
+		}
+		for _, v := range h[r.header] {
+			if strings.Contains(strings.ToLower(v), r.value) {
+				matched = append(matched, r)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// globMatch matches path segments against pattern segments, where a `**`
+// segment matches zero or more segments.
+func globMatch(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if globMatch(pattern[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeverityRules(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rules.yml")
+	ioutil.WriteFile(p, []byte(`"**/*.map": info
+"config/*.yml":
+  severity: critical
+  message: rotate the credentials in it
+"**/*.pem": low
+`), 0644)
+	rules, err := readSeverityRules(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		severity string
+		message  string
+	}{
+		{"./static/app.js.map", severityInfo, ""},
+		{"./config/database.yml", severityCritical, "rotate the credentials in it"},
+		{"./config/sub/database.yml", severityHigh, ""},
+		{"./wp-config.php", severityCritical, ""},
+		{"./app/web.config", severityCritical, ""},
+		{"./etc/nginx.conf", severityHigh, ""},
+		{"./WEB-INF/classes/application.properties", severityHigh, ""},
+		{"./server.pem", severityLow, ""},
+		{"./.git/config", severityHigh, ""},
+		{"./a/b/.env", severityCritical, ""},
+		{"./index.php", severityDefault, ""},
+	}
+	for _, tt := range tests {
+		severity, message := rules.classify(tt.path)
+		if severity != tt.severity || message != tt.message {
+			t.Errorf("expected %q to eq %q %q, got %q %q", tt.path, tt.severity, tt.message, severity, message)
+		}
+	}
+
+	if severity, _ := severityRules(nil).classify("./server.pem"); severity != severityCritical {
+		t.Errorf("expected %q to eq %q", severity, severityCritical)
+	}
+
+	for _, body := range []string{`"*.pem": severe`, `"[a": low`, `"*.pem": {level: low}`} {
+		ioutil.WriteFile(p, []byte(body), 0644)
+		if _, err := readSeverityRules(p); err == nil {
+			t.Errorf("expected %q to be an error", body)
+		}
+	}
+}
+
+func TestSeverityRules_header(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rules.yml")
+	ioutil.WriteFile(p, []byte(`"config/**":
+  severity: high
+  header: "cache-control: Public"
+  message: cached by shared caches
+"**/*.zip":
+  severity: low
+  header: Content-Disposition
+`), 0644)
+	rules, err := readSeverityRules(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if severity, _ := rules.classify("./config/app.json"); severity != severityDefault {
+		t.Errorf("expected header rules not to classify findings, got %q", severity)
+	}
+
+	h := http.Header{"Cache-Control": {"PUBLIC, max-age=600"}}
+	if rs := rules.headerRules("./config/app.yml", h); len(rs) != 1 || rs[0].message != "cached by shared caches" {
+		t.Errorf("unexpected header rules %+v", rs)
+	}
+	if rs := rules.headerRules("./config/app.yml", http.Header{"Cache-Control": {"private"}}); len(rs) != 0 {
+		t.Errorf("unexpected header rules %+v", rs)
+	}
+	if rs := rules.headerRules("./a.zip", http.Header{"Content-Disposition": {"attachment"}}); len(rs) != 1 {
+		t.Errorf("unexpected header rules %+v", rs)
+	}
+}
+
+func TestScanner_checkHeaders(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rules.yml")
+	ioutil.WriteFile(p, []byte(`"config/**": {severity: high, header: "Cache-Control: public"}`), 0644)
+	rules, err := readSeverityRules(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		w.Header().Set("Server", "nginx")
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer target.Close()
+
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), root: t.TempDir(), rules: rules, captureHeaders: []string{"server", "X-Powered-By"}}
+	res, err := s.check(context.Background(), &entry{Path: "./config/app.yml", URL: target.URL, Expect: 404}, target.URL+"/config/app.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].Kind != findingHeader || s.findings[0].Severity != severityHigh {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+	if fmt.Sprint(res.Headers) != "map[Server:nginx]" {
+		t.Errorf("expected %q to eq %q", fmt.Sprint(res.Headers), "map[Server:nginx]")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// sourceMapEntries returns an entry for the .map of every .js and .css
+// path that isn't in entries already.
+func sourceMapEntries(entries []*entry) []*entry {
+	listed := map[string]bool{}
+	for _, e := range entries {
+		listed[e.URL+" "+e.Path] = true
+	}
+	maps := []*entry{}
+	for _, e := range entries {
+		if e.storage != "" {
+			continue
+		}
+		switch strings.ToLower(path.Ext(e.Path)) {
+		case ".js", ".css":
+		default:
+			continue
+		}
+		p := e.Path + ".map"
+		if listed[e.URL+" "+p] {
+			continue
+		}
+		listed[e.URL+" "+p] = true
+		maps = append(maps, &entry{Path: p, URL: e.URL, Headers: e.Headers})
+	}
+	return maps
+}
+
+// isSourceMap tells a source map from a soft 404 page. The body may be
+// cut at maxBodyBuffer, so it is decided by the keys read before the end
+// of the head: a JSON object with a version and mappings or sections.
+func isSourceMap(body []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return false
+	}
+	var version float64
+	mapped := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch key {
+		case "version":
+			if err := dec.Decode(&version); err != nil {
+				return false
+			}
+			continue
+		case "mappings", "sections":
+			mapped = true
+		}
+		if version > 0 && mapped {
+			return true
+		}
+		if err := skipJSONValue(dec); err != nil {
+			break
+		}
+	}
+	return version > 0 && mapped
+}
+
+// skipJSONValue reads past the next value of dec token by token, so that
+// large values aren't held in memory.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// checkSourceMaps requests the source map of every script and stylesheet
+// of entries and reports the ones that are served.
+func (s *scanner) checkSourceMaps(ctx context.Context, entries []*entry) error {
+	return s.probeAll(ctx, sourceMapEntries(entries), findingSourceMap, "This source map is published %s at %s", func(e *entry, r *Response) bool {
+		return isSourceMap(r.Body)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScanner_checkSourceMaps(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/js/app.js.map":
+			fmt.Fprint(w, `{"version":3,"sources":["../src/app.ts"],"mappings":"AAAA"}`)
+		default:
+			fmt.Fprint(w, "<html>not found</html>")
+		}
+	}))
+	defer target.Close()
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	err := s.checkSourceMaps(ctx, []*entry{
+		{Path: "./js/app.js", URL: target.URL},
+		{Path: "./css/app.css", URL: target.URL},
+		{Path: "./css/app.css.map", URL: target.URL},
+		{Path: "./index.php", URL: target.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 1 || s.findings[0].Kind != findingSourceMap || s.findings[0].URL != target.URL+"/js/app.js.map" {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+	// app.css.map is checked as an input path already
+	if s.stats.requests != 1 {
+		t.Errorf("expected %d requests, got %d", 1, s.stats.requests)
+	}
+}
+
+func TestIsSourceMap(t *testing.T) {
+	large := `{"version":3,"sources":["a.js"],"mappings":"` + strings.Repeat("AAAA;", maxBodyBuffer) + `"}`
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{`{"version":3,"sources":["a.js"],"names":[],"mappings":"AAAA"}`, true},
+		{`{"version":3,"sections":[]}`, true},
+		{`{"sources":["a.js"],"mappings":"AAAA","version":3}`, true},
+		{large[:maxBodyBuffer], true},
+		{`{"version":3,"sourcesContent":["` + strings.Repeat("x", maxBodyBuffer), false},
+		{`{"version":3}`, false},
+		{`<html>not found</html>`, false},
+		{`[]`, false},
+	}
+	for _, tt := range tests {
+		if got := isSourceMap([]byte(tt.body)); got != tt.expected {
+			t.Errorf("%.40s: expected %v to eq %v", tt.body, got, tt.expected)
+		}
+	}
+}
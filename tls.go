@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the client TLS config. Empty versions and ciphers
+// leave the Go defaults in place. Cipher suites only apply up to TLS 1.2,
+// TLS 1.3 suites are not configurable.
+func newTLSConfig(insecure bool, min, max, ciphers string) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: insecure}
+	for _, v := range []struct {
+		name string
+		dst  *uint16
+	}{{min, &c.MinVersion}, {max, &c.MaxVersion}} {
+		if v.name == "" {
+			continue
+		}
+		ver, ok := tlsVersions[v.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version: %s", v.name)
+		}
+		*v.dst = ver
+	}
+	if c.MinVersion != 0 && c.MaxVersion != 0 && c.MinVersion > c.MaxVersion {
+		return nil, fmt.Errorf("tls-min %s is greater than tls-max %s", min, max)
+	}
+
+	if ciphers != "" {
+		suites := map[string]uint16{}
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[s.Name] = s.ID
+		}
+		for _, name := range strings.Split(ciphers, ",") {
+			id, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown cipher suite: %s", name)
+			}
+			c.CipherSuites = append(c.CipherSuites, id)
+		}
+	}
+	return c, nil
+}
+
+// tlsReport collects the certificate chain presented by each host.
+type tlsReport struct {
+	warnDays int
+
+	mu    sync.Mutex
+	hosts map[string][]*x509.Certificate
+}
+
+func newTLSReport(warnDays int) *tlsReport {
+	return &tlsReport{
+		warnDays: warnDays,
+		hosts:    map[string][]*x509.Certificate{},
+	}
+}
+
+// record keeps the first chain seen for host and warns about certificates
+// expiring within warnDays.
+func (t *tlsReport) record(host string, cs *tls.ConnectionState) {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return
+	}
+	t.mu.Lock()
+	if _, ok := t.hosts[host]; ok {
+		t.mu.Unlock()
+		return
+	}
+	t.hosts[host] = cs.PeerCertificates
+	t.mu.Unlock()
+
+	for _, c := range cs.PeerCertificates {
+		if days := daysUntil(c.NotAfter, time.Now()); days < t.warnDays {
+			logrus.Warnf("certificate %s for %s expires in %d days", c.Subject, host, days)
+		}
+	}
+}
+
+func (t *tlsReport) write(w io.Writer, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hosts := make([]string, 0, len(t.hosts))
+	for h := range t.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	for _, h := range hosts {
+		chain := t.hosts[h]
+		leaf := chain[0]
+		fmt.Fprintf(w, "host: %s\n", h)
+		fmt.Fprintf(w, "  subject: %s\n", leaf.Subject)
+		fmt.Fprintf(w, "  issuer: %s\n", leaf.Issuer)
+		fmt.Fprintf(w, "  sans: %s\n", strings.Join(certSANs(leaf), ", "))
+		for _, c := range chain {
+			fmt.Fprintf(w, "  expires: %s (%d days) %s\n", c.NotAfter.Format("2006-01-02"), daysUntil(c.NotAfter, now), c.Subject)
+		}
+	}
+}
+
+func certSANs(c *x509.Certificate) []string {
+	sans := append([]string{}, c.DNSNames...)
+	for _, ip := range c.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
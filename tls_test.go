@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSReport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	report := newTLSReport(30)
+	f := &httpFetcher{client: ts.Client(), tlsReport: report}
+	if _, err := f.Fetch(context.Background(), &entry{}, ts.URL+"/index.php"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	report.write(out, time.Now())
+	for _, expected := range []string{"host: " + strings.TrimPrefix(ts.URL, "https://"), "sans: example.com, *.example.com, 127.0.0.1", "expires: "} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q to contain %q", out.String(), expected)
+		}
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	c, err := newTLSConfig(false, "1.0", "1.1", "TLS_RSA_WITH_AES_128_CBC_SHA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MinVersion != tls.VersionTLS10 || c.MaxVersion != tls.VersionTLS11 {
+		t.Errorf("unexpected versions %x-%x", c.MinVersion, c.MaxVersion)
+	}
+	if len(c.CipherSuites) != 1 || c.CipherSuites[0] != tls.TLS_RSA_WITH_AES_128_CBC_SHA {
+		t.Errorf("unexpected cipher suites %v", c.CipherSuites)
+	}
+
+	for _, args := range [][]string{{"1.4", "", ""}, {"1.3", "1.2", ""}, {"", "", "TLS_NOPE"}} {
+		if _, err := newTLSConfig(false, args[0], args[1], args[2]); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const tuiInterval = 200 * time.Millisecond
+
+// tui is a full screen terminal UI for interactive scans. It reads keys
+// from and draws to the controlling terminal, so paths can still be piped
+// into stdin. Log lines are written to it by logrus and split into the
+// findings pane and a last-message line.
+type tui struct {
+	tty   *os.File
+	state *terminal.State
+	gate  *gate
+	stats *stats
+	total int
+	done  int64
+	quit  func()
+
+	mu       sync.Mutex
+	active   map[string]time.Time
+	findings []string
+	last     string
+	scroll   int
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTUI(g *gate, st *stats, total int, quit func()) (*tui, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	state, err := terminal.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	return &tui{
+		tty:    tty,
+		state:  state,
+		gate:   g,
+		stats:  st,
+		total:  total,
+		quit:   quit,
+		active: map[string]time.Time{},
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+func (t *tui) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, l := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if strings.HasPrefix(l, "FOUND") {
+			t.findings = append(t.findings, l)
+		} else {
+			t.last = l
+		}
+	}
+	return len(b), nil
+}
+
+func (t *tui) begin(path string) {
+	t.mu.Lock()
+	t.active[path] = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tui) end(path string) {
+	t.mu.Lock()
+	delete(t.active, path)
+	t.mu.Unlock()
+	atomic.AddInt64(&t.done, 1)
+}
+
+func (t *tui) run() {
+	fmt.Fprint(t.tty, "\x1b[?1049h\x1b[?25l")
+	go t.readKeys()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		tick := time.NewTicker(tuiInterval)
+		defer tick.Stop()
+		for {
+			t.draw()
+			select {
+			case <-tick.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish restores the terminal and returns the findings shown on screen.
+func (t *tui) finish() []string {
+	close(t.stop)
+	t.wg.Wait()
+	fmt.Fprint(t.tty, "\x1b[?25h\x1b[?1049l")
+	terminal.Restore(int(t.tty.Fd()), t.state)
+	t.tty.Close()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.findings
+}
+
+func (t *tui) readKeys() {
+	r := bufio.NewReader(t.tty)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		limit, _, paused := t.gate.state()
+		switch b {
+		case 'p', ' ':
+			t.gate.setPaused(!paused)
+		case '+', '=':
+			t.gate.setLimit(limit + 1)
+		case '-', '_':
+			t.gate.setLimit(limit - 1)
+		case 'k':
+			t.scrollBy(-1)
+		case 'j':
+			t.scrollBy(1)
+		case 0x1b:
+			// arrow keys arrive as ESC [ A / ESC [ B
+			if seq, err := r.Peek(2); err == nil && seq[0] == '[' {
+				r.Discard(2)
+				switch seq[1] {
+				case 'A':
+					t.scrollBy(-1)
+				case 'B':
+					t.scrollBy(1)
+				}
+			}
+		case 'q', 0x03:
+			t.quit()
+			return
+		}
+	}
+}
+
+func (t *tui) scrollBy(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scroll += n
+	if t.scroll > len(t.findings)-1 {
+		t.scroll = len(t.findings) - 1
+	}
+	if t.scroll < 0 {
+		t.scroll = 0
+	}
+}
+
+func (t *tui) draw() {
+	width, height, err := terminal.GetSize(int(t.tty.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	limit, active, paused := t.gate.state()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := "running"
+	if paused {
+		status = "paused"
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s  %d/%d  requests %d  errors %d  findings %d  concurrency %d (%d active)  %s",
+			Name, Version, atomic.LoadInt64(&t.done), t.total, atomic.LoadInt64(&t.stats.requests),
+			atomic.LoadInt64(&t.stats.errors), atomic.LoadInt64(&t.stats.findings), limit, active, status),
+		"",
+		"Workers:",
+	}
+
+	paths := make([]string, 0, len(t.active))
+	for p := range t.active {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		lines = append(lines, fmt.Sprintf("  %5.1fs %s", time.Since(t.active[p]).Seconds(), p))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("Findings (%d):", len(t.findings)))
+	room := height - len(lines) - 3
+	if room < 1 {
+		room = 1
+	}
+	end := len(t.findings) - t.scroll
+	start := end - room
+	if start < 0 {
+		start = 0
+	}
+	for _, f := range t.findings[start:end] {
+		lines = append(lines, "  "+f)
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString("\x1b[H\x1b[2J")
+	for _, l := range lines {
+		b.WriteString(truncate(l, width) + "\r\n")
+	}
+	fmt.Fprintf(b, "\x1b[%d;1H%s\r\n", height-1, truncate(t.last, width))
+	b.WriteString(truncate("p: pause/resume  +/-: concurrency  j/k: scroll findings  q: quit", width))
+	t.tty.Write(b.Bytes())
+}
+
+func truncate(s string, width int) string {
+	if len(s) > width {
+		return s[:width]
+	}
+	return s
+}
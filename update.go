@@ -0,0 +1,249 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	releaseRepo = "pyama86/pmr"
+	// checksumsAsset is the sha256sum output for the archives of a
+	// release, uploaded along with them by misc/build.
+	checksumsAsset = "SHA256SUMS"
+	// maxAssetSize bounds release downloads, which are held in memory.
+	maxAssetSize = 200 << 20
+)
+
+type githubRelease struct {
+	TagName string         `json:"tag_name"`
+	Assets  []*githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset returns the download url of the asset called name.
+func (r *githubRelease) asset(name string) (string, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no %s", r.TagName, name)
+}
+
+func (c *githubClient) latestRelease(repo string) (*githubRelease, error) {
+	r := &githubRelease{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("%s/repos/%s/releases/latest", c.api, repo), nil, http.StatusOK, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// runSelfUpdate replaces the running binary with the one of the latest
+// GitHub release, after checking it against the checksums of the release.
+func (cli *CLI) runSelfUpdate(args []string) int {
+	var (
+		api   string
+		token string
+		check bool
+	)
+	flags := flag.NewFlagSet(Name+" self-update", flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.StringVar(&api, "github-api", githubAPI, "GitHub API url, e.g. https://github.example.com/api/v3")
+	flags.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub token to avoid rate limits(default $GITHUB_TOKEN)")
+	flags.BoolVar(&check, "check", false, "only print whether a newer version is released")
+	if err := cli.parse(flags, args[1:]); err != nil {
+		return ExitCodeError
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	gh := &githubClient{client: client, api: strings.TrimRight(api, "/"), token: token}
+	rel, err := gh.latestRelease(releaseRepo)
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	latest := releaseVersion(rel.TagName)
+	if !newerVersion(latest, Version) {
+		fmt.Fprintf(cli.outStream, "%s %s is the latest version\n", Name, Version)
+		return ExitCodeOK
+	}
+	if check {
+		fmt.Fprintf(cli.outStream, "%s %s is released, this is %s\n", Name, latest, Version)
+		return ExitCodeOK
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	bin, err := downloadRelease(client, rel, runtime.GOOS+"_"+runtime.GOARCH+".zip")
+	if err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	if err := replaceExecutable(exe, bin); err != nil {
+		logrus.Error(err)
+		return ExitCodeError
+	}
+	fmt.Fprintf(cli.outStream, "updated %s from %s to %s\n", exe, Version, latest)
+	return ExitCodeOK
+}
+
+// downloadRelease downloads the archive called name from rel, verifies it
+// against the checksums of the release and returns the binary in it.
+func downloadRelease(client *http.Client, rel *githubRelease, name string) ([]byte, error) {
+	sumsURL, err := rel.asset(checksumsAsset)
+	if err != nil {
+		return nil, err
+	}
+	archiveURL, err := rel.asset(name)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := download(client, sumsURL)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := download(client, archiveURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(sums, name, archive); err != nil {
+		return nil, err
+	}
+	return unzipFile(archive, Name)
+}
+
+func download(client *http.Client, u string) ([]byte, error) {
+	res, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, res.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxAssetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxAssetSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", u, maxAssetSize)
+	}
+	return b, nil
+}
+
+// verifyChecksum checks b against the line for name in sums, in the
+// format of sha256sum.
+func verifyChecksum(sums []byte, name string, b []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != strings.ToLower(fields[0]) {
+			return fmt.Errorf("%s: checksum mismatch, got %s, expected %s", name, got, fields[0])
+		}
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s: no checksum in %s", name, checksumsAsset)
+}
+
+// unzipFile returns the file called name in the zip archive b.
+func unzipFile(b []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if filepath.Base(f.Name) != name || f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(io.LimitReader(r, maxAssetSize))
+	}
+	return nil, fmt.Errorf("%s is not in the archive", name)
+}
+
+// replaceExecutable writes b next to path and renames it over path, so
+// the running binary is never left half written.
+func replaceExecutable(path string, b []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()|0111); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// releaseVersion returns the version of a release tag like
+// "v0.4.1-1a2b3c4".
+func releaseVersion(tag string) string {
+	return strings.SplitN(strings.TrimPrefix(tag, "v"), "-", 2)[0]
+}
+
+// newerVersion reports whether the dotted version a is newer than b.
+func newerVersion(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
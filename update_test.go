@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	for _, c := range []struct {
+		a, b   string
+		expect bool
+	}{
+		{"0.4.1", "0.3.0", true},
+		{"0.10.0", "0.9.9", true},
+		{"0.4", "0.4.0", false},
+		{"0.4.0", "0.4.1", false},
+		{releaseVersion("v1.0.0-1a2b3c4"), "0.9.0", true},
+	} {
+		if got := newerVersion(c.a, c.b); got != c.expect {
+			t.Errorf("expected newerVersion(%q, %q) to eq %v", c.a, c.b, c.expect)
+		}
+	}
+}
+
+func TestDownloadRelease(t *testing.T) {
+	archive := &bytes.Buffer{}
+	zw := zip.NewWriter(archive)
+	w, _ := zw.Create("./pmr")
+	w.Write([]byte("new binary"))
+	zw.Close()
+	sum := sha256.Sum256(archive.Bytes())
+	sums := fmt.Sprintf("%x  linux_amd64.zip\n", sum)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/SHA256SUMS":
+			fmt.Fprint(w, sums)
+		case "/linux_amd64.zip":
+			w.Write(archive.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	rel := &githubRelease{TagName: "v9.0.0-abc", Assets: []*githubAsset{
+		{Name: "SHA256SUMS", URL: ts.URL + "/SHA256SUMS"},
+		{Name: "linux_amd64.zip", URL: ts.URL + "/linux_amd64.zip"},
+	}}
+
+	bin, err := downloadRelease(ts.Client(), rel, "linux_amd64.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bin) != "new binary" {
+		t.Errorf("expected %q to eq %q", bin, "new binary")
+	}
+	if _, err := downloadRelease(ts.Client(), rel, "darwin_amd64.zip"); err == nil {
+		t.Error("expected a missing archive to be an error")
+	}
+
+	sums = fmt.Sprintf("%x  linux_amd64.zip\n", sha256.Sum256([]byte("tampered")))
+	if _, err := downloadRelease(ts.Client(), rel, "linux_amd64.zip"); err == nil {
+		t.Error("expected a checksum mismatch to be an error")
+	}
+
+	exe := filepath.Join(t.TempDir(), "pmr")
+	ioutil.WriteFile(exe, []byte("old binary"), 0755)
+	if err := replaceExecutable(exe, bin); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(exe)
+	fi, _ := os.Stat(exe)
+	if string(b) != "new binary" || fi.Mode().Perm() != 0755 {
+		t.Errorf("unexpected executable %q %s", b, fi.Mode())
+	}
+}
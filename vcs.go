@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// vcsFile is a metadata file of a version control system. match tells it
+// from a soft 404 page answering 200 for any path.
+type vcsFile struct {
+	path  string
+	match func(body []byte) bool
+}
+
+var gitHead = regexp.MustCompile(`^(ref: refs/|[0-9a-f]{40}\s*$)`)
+
+var vcsFiles = []*vcsFile{
+	{".git/HEAD", func(b []byte) bool { return gitHead.Match(b) }},
+	{".git/config", func(b []byte) bool { return bytes.Contains(b, []byte("[core]")) }},
+	{".svn/entries", func(b []byte) bool {
+		l := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+		return (l != "" && strings.Trim(l, "0123456789") == "") || strings.HasPrefix(l, "<?xml")
+	}},
+	{".hg/requires", func(b []byte) bool {
+		return bytes.Contains(b, []byte("revlogv1")) || bytes.Contains(b, []byte("store"))
+	}},
+}
+
+// vcsDirs returns the top of the url and every top level directory of
+// the paths of entries, where a checkout is most likely to be deployed.
+func vcsDirs(entries []*entry) []*entry {
+	seen := map[string]bool{}
+	dirs := []*entry{}
+	add := func(e *entry, d string) {
+		if !seen[e.URL+" "+d] {
+			seen[e.URL+" "+d] = true
+			dirs = append(dirs, &entry{Path: d, URL: e.URL, Headers: e.Headers})
+		}
+	}
+	for _, e := range entries {
+		if e.storage != "" || isTemplate(e.URL) {
+			continue
+		}
+		add(e, "./")
+		p := strings.TrimPrefix(slashPath(e.Path), "./")
+		abs := strings.HasPrefix(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		if i := strings.Index(p, "/"); i > 0 {
+			d := "./" + p[:i+1]
+			if abs {
+				d = "/" + p[:i+1]
+			}
+			add(e, d)
+		}
+	}
+	return dirs
+}
+
+// checkVCS requests the version control metadata under the directories of
+// entries and reports the ones that are served.
+func (s *scanner) checkVCS(ctx context.Context, entries []*entry) error {
+	es := []*entry{}
+	files := map[*entry]*vcsFile{}
+	for _, d := range vcsDirs(entries) {
+		for _, vf := range vcsFiles {
+			e := &entry{Path: path.Join(d.Path, vf.path), URL: d.URL, Headers: d.Headers}
+			if strings.HasPrefix(d.Path, "./") {
+				e.Path = "./" + e.Path
+			}
+			es = append(es, e)
+			files[e] = vf
+		}
+	}
+	return s.probeAll(ctx, es, findingVCS, "This version control metadata is published %s at %s", func(e *entry, r *Response) bool {
+		return files[e].match(r.Body)
+	})
+}
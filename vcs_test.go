@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVCSDirs(t *testing.T) {
+	dirs := vcsDirs([]*entry{
+		{Path: "./app/a/b.php", URL: "https://example.com"},
+		{Path: "./app/c.php", URL: "https://example.com"},
+		{Path: "./index.php", URL: "https://example.com"},
+		{Path: "/var/www/d.php", URL: "https://example.com"},
+	})
+	got := []string{}
+	for _, d := range dirs {
+		got = append(got, d.Path)
+	}
+	expect := fmt.Sprint([]string{"./", "./app/", "/var/"})
+	if fmt.Sprint(got) != expect {
+		t.Errorf("expected %q to eq %q", fmt.Sprint(got), expect)
+	}
+}
+
+func TestScanner_checkVCS(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/.git/HEAD":
+			fmt.Fprint(w, "ref: refs/heads/main\n")
+		case "/app/.git/config":
+			fmt.Fprint(w, "[core]\n\trepositoryformatversion = 0\n")
+		default:
+			// a soft 404
+			fmt.Fprint(w, "<html>not found</html>")
+		}
+	}))
+	defer target.Close()
+
+	ctx := context.Background()
+	s := &scanner{fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil), gate: newGate(ctx, 2)}
+	if err := s.checkVCS(ctx, []*entry{{Path: "./app/index.php", URL: target.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.findings) != 2 {
+		t.Fatalf("unexpected findings %+v", s.findings)
+	}
+	for _, f := range s.findings {
+		if f.Kind != findingVCS || f.Severity != severityHigh {
+			t.Errorf("unexpected finding %+v", f)
+		}
+	}
+	if s.stats.requests != 8 {
+		t.Errorf("expected %d requests, got %d", 8, s.stats.requests)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// wafSignature recognizes the block page of a WAF or CDN.
+type wafSignature struct {
+	name  string
+	match func(r *Response) bool
+}
+
+var wafSignatures = []*wafSignature{
+	{"Cloudflare", func(r *Response) bool {
+		return r.Header.Get("Cf-Ray") != "" && strings.EqualFold(r.Header.Get("Server"), "cloudflare") &&
+			(bytes.Contains(r.Body, []byte("Attention Required! | Cloudflare")) ||
+				bytes.Contains(r.Body, []byte("cf-error-details")) ||
+				bytes.Contains(r.Body, []byte("Just a moment...")))
+	}},
+	{"Akamai", func(r *Response) bool {
+		return strings.HasPrefix(r.Header.Get("Server"), "AkamaiGHost") &&
+			bytes.Contains(r.Body, []byte("Access Denied")) && bytes.Contains(r.Body, []byte("Reference&#32;&#35;"))
+	}},
+	{"AWS WAF", func(r *Response) bool {
+		if strings.EqualFold(r.Header.Get("X-Amzn-Waf-Action"), "block") {
+			return true
+		}
+		return (r.Header.Get("X-Amz-Cf-Id") != "" || strings.HasPrefix(r.Header.Get("Server"), "awselb")) &&
+			bytes.Contains(r.Body, []byte("Request blocked"))
+	}},
+}
+
+// detectWAF returns the name of the WAF whose block page r is, if any.
+// Only statuses WAFs block with are looked at.
+func detectWAF(r *Response) string {
+	switch r.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return ""
+	}
+	for _, w := range wafSignatures {
+		if w.match(r) {
+			return w.name
+		}
+	}
+	return ""
+}
+
+// blocked records that a WAF answered u instead of the site, so e was
+// not checked. With wafBackoff the concurrency is halved and requests
+// are paused for that long.
+func (s *scanner) blocked(e *entry, u, waf string) {
+	atomic.AddInt64(&s.stats.blocked, 1)
+	logrus.WithField("waf", waf).Warnf("request: %s blocked, %s was not checked", u, e.Path)
+	if s.wafBackoff <= 0 || s.gate == nil || !atomic.CompareAndSwapInt32(&s.backingOff, 0, 1) {
+		return
+	}
+	limit, _, _ := s.gate.state()
+	if limit = limit / 2; limit < 1 {
+		limit = 1
+	}
+	s.gate.setLimit(limit)
+	s.gate.setPaused(true)
+	logrus.Warnf("blocked by %s, pausing for %s and lowering the concurrency to %d", waf, s.wafBackoff, limit)
+	time.AfterFunc(s.wafBackoff, func() {
+		s.gate.setPaused(false)
+		atomic.StoreInt32(&s.backingOff, 0)
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDetectWAF(t *testing.T) {
+	tests := []struct {
+		status int
+		header http.Header
+		body   string
+		expect string
+	}{
+		{403, http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"1-NRT"}}, "<title>Attention Required! | Cloudflare</title>", "Cloudflare"},
+		{200, http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"1-NRT"}}, "<title>Attention Required! | Cloudflare</title>", ""},
+		{403, http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"1-NRT"}}, "<h1>403 Forbidden</h1>", ""},
+		{403, http.Header{"Server": {"AkamaiGHost"}}, "<H1>Access Denied</H1> Reference&#32;&#35;18.1", "Akamai"},
+		{403, http.Header{"X-Amzn-Waf-Action": {"block"}}, "", "AWS WAF"},
+		{403, http.Header{"X-Amz-Cf-Id": {"abc"}}, "<H1>403 ERROR</H1> Request blocked.", "AWS WAF"},
+		{403, http.Header{"Server": {"nginx"}}, "Request blocked", ""},
+	}
+	for i, tt := range tests {
+		if got := detectWAF(&Response{StatusCode: tt.status, Header: tt.header, Body: []byte(tt.body)}); got != tt.expect {
+			t.Errorf("%d: expected %q to eq %q", i, got, tt.expect)
+		}
+	}
+}
+
+func TestScanner_blocked(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Waf-Action", "block")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<?php")
+	}))
+	defer target.Close()
+
+	ctx := context.Background()
+	s := &scanner{
+		fetchers:   newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		gate:       newGate(ctx, 4),
+		wafBackoff: time.Hour,
+	}
+	res, err := s.check(ctx, &entry{Path: "./index.php", Head: []string{"<?php"}}, target.URL+"/index.php")
+	if err != nil || res.Verdict != verdictBlocked {
+		t.Fatalf("expected the request to be blocked, got %+v %v", res, err)
+	}
+	if s.stats.blocked != 1 {
+		t.Errorf("expected %d blocked, got %d", 1, s.stats.blocked)
+	}
+	if limit, _, paused := s.gate.state(); limit != 2 || !paused {
+		t.Errorf("expected the gate to back off, got limit %d paused %v", limit, paused)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// watchDelay is how long a file has to stay unchanged before it is
+// checked, so that a file being written is requested once.
+const watchDelay = 500 * time.Millisecond
+
+// watcher checks files under a directory against the target as soon as
+// they are created or modified. Paths are taken as the watcher reports
+// them, so like the output of find they have to resolve against the url.
+type watcher struct {
+	fs      *fsnotify.Watcher
+	baseURL string
+	delay   time.Duration
+	// extensions limits the files checked when set.
+	extensions *extFilter
+	// root makes paths relative to it when set, like -root does for
+	// paths read from stdin.
+	root string
+	// headers are sent with every request.
+	headers map[string]string
+}
+
+func newWatcher(dir, baseURL string) (*watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{fs: fs, baseURL: baseURL, delay: watchDelay}
+	if err := w.add(dir); err != nil {
+		fs.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// add watches dir and every directory below it, since fsnotify does not
+// watch recursively.
+func (w *watcher) add(dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return w.fs.Add(p)
+		}
+		return nil
+	})
+}
+
+// run checks changed files through the gate of s until ctx is done.
+// Request errors are logged instead of stopping the watch.
+func (w *watcher) run(ctx context.Context, s *scanner) {
+	defer w.fs.Close()
+	// a changed file is checked again while the target may have changed
+	// too, so responses are never reused
+	s.cache = nil
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	pending := map[string]time.Time{}
+	tick := time.NewTicker(w.delay / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-w.fs.Errors:
+			logrus.Warn(err)
+		case ev := <-w.fs.Events:
+			if ev.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			fi, err := os.Stat(ev.Name)
+			if err != nil {
+				continue
+			}
+			if fi.IsDir() {
+				if ev.Op&fsnotify.Create != 0 {
+					// files created with the directory are missed by the
+					// watch, so check them too
+					if err := w.add(ev.Name); err != nil {
+						logrus.Warn(err)
+					}
+					filepath.Walk(ev.Name, func(p string, fi os.FileInfo, err error) error {
+						if err == nil && fi.Mode().IsRegular() {
+							pending[p] = time.Now()
+						}
+						return nil
+					})
+				}
+				continue
+			}
+			if fi.Mode().IsRegular() {
+				pending[ev.Name] = time.Now()
+			}
+		case now := <-tick.C:
+			for p, t := range pending {
+				if now.Sub(t) < w.delay {
+					continue
+				}
+				delete(pending, p)
+				if w.extensions != nil && !w.extensions.match(p) {
+					continue
+				}
+				if !s.gate.acquire(ctx) {
+					return
+				}
+				e := &entry{Path: w.path(p), URL: w.baseURL, Headers: w.headers}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer s.gate.release()
+					if err := s.request(ctx, e); err != nil {
+						logrus.Error(err)
+					}
+				}()
+			}
+		}
+	}
+}
+
+func (w *watcher) path(p string) string {
+	if w.root == "" {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(w.root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
+	}
+	return "./" + filepath.ToSlash(rel)
+}
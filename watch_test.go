@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatcher_run(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<?php secret")
+	}))
+	defer target.Close()
+
+	dir := t.TempDir()
+	w, err := newWatcher(dir, target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.delay = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &scanner{
+		fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		gate:     newGate(ctx, 1),
+	}
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx, s)
+		close(done)
+	}()
+
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "secret.php"), []byte("<?php secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; atomic.LoadInt64(&s.stats.findings) == 0 && i < 100; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(s.findings) != 1 || s.findings[0].Path != filepath.Join(sub, "secret.php") {
+		t.Errorf("unexpected findings %+v", s.findings)
+	}
+}
+
+func TestWatcher_runChanged(t *testing.T) {
+	body := atomic.Value{}
+	body.Store("v1")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body.Load())
+	}))
+	defer target.Close()
+
+	dir := t.TempDir()
+	w, err := newWatcher(dir, target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.delay = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &scanner{
+		fetchers: newFetchers(target.Client(), 3, &tls.Config{}, nil),
+		gate:     newGate(ctx, 1),
+		cache:    newResponseCache(1 << 20),
+	}
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx, s)
+		close(done)
+	}()
+
+	p := filepath.Join(dir, "secret.php")
+	for i, v := range []string{"v1", "v2"} {
+		body.Store(v)
+		if err := os.WriteFile(p, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; atomic.LoadInt64(&s.stats.findings) <= int64(i) && j < 100; j++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	cancel()
+	<-done
+
+	if len(s.findings) != 2 {
+		t.Errorf("expected the changed file to be found again, got %+v", s.findings)
+	}
+}